@@ -0,0 +1,241 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mxwell/wac/model"
+)
+
+const (
+	ModeExact     = "exact"
+	ModeTokens    = "tokens"
+	ModeFloat     = "float"
+	ModeYesNo     = "yesno"
+	ModeUnordered = "unordered"
+	ModeExternal  = "external"
+)
+
+var Modes = []string{ModeExact, ModeTokens, ModeFloat, ModeYesNo, ModeUnordered, ModeExternal}
+
+const DefaultEpsilon = 1e-6
+
+type Result struct {
+	Ok      bool
+	Message string
+}
+
+type Checker interface {
+	Check(inputPath string, expectedPath string, resultPath string) (*Result, error)
+}
+
+/* Create checker by its specification. Relative path to checker program is resolved against dir. */
+func New(spec model.Checker, dir string) (Checker, error) {
+	switch spec.Mode {
+	case "", ModeExact:
+		return exactChecker{}, nil
+	case ModeTokens:
+		return tokensChecker{equalTokens}, nil
+	case ModeFloat:
+		epsilon := spec.Epsilon
+		if epsilon <= 0 {
+			epsilon = DefaultEpsilon
+		}
+		return tokensChecker{func(a, b string) bool { return equalFloats(a, b, epsilon) }}, nil
+	case ModeYesNo:
+		return tokensChecker{strings.EqualFold}, nil
+	case ModeUnordered:
+		return unorderedChecker{}, nil
+	case ModeExternal:
+		if len(spec.Program) == 0 {
+			return nil, fmt.Errorf("checker program is not specified")
+		}
+		program := spec.Program
+		if !filepath.IsAbs(program) {
+			program = filepath.Join(dir, program)
+		}
+		return externalChecker{program}, nil
+	}
+	return nil, fmt.Errorf("unknown checker mode '%s', expected one of: %s", spec.Mode, strings.Join(Modes, ", "))
+}
+
+func ok() *Result {
+	return &Result{true, ""}
+}
+
+func wrong(format string, a ...interface{}) *Result {
+	return &Result{false, fmt.Sprintf(format, a...)}
+}
+
+/* Lines of the file with trailing spaces removed; trailing empty lines are dropped */
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), math.MaxInt32)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), " \t\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error when reading from '%s': %s", path, err)
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, nil
+}
+
+func readTokens(path string) ([]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(b)), nil
+}
+
+func readBoth(read func(string) ([]string, error), expectedPath string, resultPath string) ([]string, []string, error) {
+	expected, err := read(expectedPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read expected output: %s", err)
+	}
+	result, err := read(resultPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read solution output: %s", err)
+	}
+	return expected, result, nil
+}
+
+/* Line by line comparison ignoring trailing spaces */
+type exactChecker struct{}
+
+func (c exactChecker) Check(inputPath string, expectedPath string, resultPath string) (*Result, error) {
+	expected, result, err := readBoth(readLines, expectedPath, resultPath)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(expected) && i < len(result); i++ {
+		if expected[i] != result[i] {
+			return wrong("line %d differs", i+1), nil
+		}
+	}
+	if len(expected) != len(result) {
+		return wrong("expected %d line(s), found %d", len(expected), len(result)), nil
+	}
+	return ok(), nil
+}
+
+/* Comparison of whitespace-separated tokens with a custom equality */
+type tokensChecker struct {
+	equal func(expected string, result string) bool
+}
+
+func (c tokensChecker) Check(inputPath string, expectedPath string, resultPath string) (*Result, error) {
+	expected, result, err := readBoth(readTokens, expectedPath, resultPath)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(expected) && i < len(result); i++ {
+		if !c.equal(expected[i], result[i]) {
+			return wrong("token %d differs: expected '%s', found '%s'", i+1, expected[i], result[i]), nil
+		}
+	}
+	if len(expected) != len(result) {
+		return wrong("expected %d token(s), found %d", len(expected), len(result)), nil
+	}
+	return ok(), nil
+}
+
+func equalTokens(a string, b string) bool {
+	return a == b
+}
+
+/* Tokens are equal if they match as strings or as numbers within absolute or relative epsilon */
+func equalFloats(expected string, result string, epsilon float64) bool {
+	if expected == result {
+		return true
+	}
+	x, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.ParseFloat(result, 64)
+	if err != nil || math.IsNaN(y) {
+		return false
+	}
+	delta := math.Abs(x - y)
+	return delta <= epsilon || delta <= epsilon*math.Abs(x)
+}
+
+/* Lines are compared as multisets */
+type unorderedChecker struct{}
+
+func (c unorderedChecker) Check(inputPath string, expectedPath string, resultPath string) (*Result, error) {
+	expected, result, err := readBoth(readLines, expectedPath, resultPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(expected) != len(result) {
+		return wrong("expected %d line(s), found %d", len(expected), len(result)), nil
+	}
+	sort.Strings(expected)
+	sort.Strings(result)
+	for i := range expected {
+		if expected[i] != result[i] {
+			return wrong("line '%s' is expected, but not found", expected[i]), nil
+		}
+	}
+	return ok(), nil
+}
+
+/* Exit codes of testlib checkers */
+const (
+	ExitOk                = 0
+	ExitWrongAnswer       = 1
+	ExitPresentationError = 2
+	ExitFail              = 3
+)
+
+/* Testlib-style checker program, invoked as `checker input expected actual` */
+type externalChecker struct {
+	program string
+}
+
+func (c externalChecker) Check(inputPath string, expectedPath string, resultPath string) (*Result, error) {
+	var output bytes.Buffer
+	command := exec.Command(c.program, inputPath, expectedPath, resultPath)
+	command.Stdout = &output
+	command.Stderr = &output
+	err := command.Run()
+	return InterpretExit(c.program, err, strings.TrimSpace(output.String()))
+}
+
+/* Turn the exit status of a testlib-style program (checker or interactor) into result */
+func InterpretExit(program string, err error, message string) (*Result, error) {
+	if err == nil {
+		return &Result{true, message}, nil
+	}
+	exitErr, isExit := err.(*exec.ExitError)
+	if !isExit {
+		return nil, fmt.Errorf("failed to run %s: %s", program, err)
+	}
+	switch exitErr.ExitCode() {
+	case ExitWrongAnswer, ExitPresentationError:
+		return &Result{false, message}, nil
+	case ExitFail:
+		return nil, fmt.Errorf("%s failed: %s", program, message)
+	}
+	return nil, fmt.Errorf("%s exited with code %d: %s", program, exitErr.ExitCode(), message)
+}
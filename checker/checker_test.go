@@ -0,0 +1,173 @@
+package checker
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mxwell/wac/model"
+)
+
+/* Run the checker on expected and result written to files */
+func check(t *testing.T, spec model.Checker, expected string, result string) *Result {
+	t.Helper()
+	dir := t.TempDir()
+	expectedPath := filepath.Join(dir, "expected")
+	resultPath := filepath.Join(dir, "result")
+	if err := ioutil.WriteFile(expectedPath, []byte(expected), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(resultPath, []byte(result), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(spec, dir)
+	if err != nil {
+		t.Fatalf("New(%+v): %s", spec, err)
+	}
+	r, err := c.Check(filepath.Join(dir, "input"), expectedPath, resultPath)
+	if err != nil {
+		t.Fatalf("Check: %s", err)
+	}
+	return r
+}
+
+func TestModes(t *testing.T) {
+	cases := []struct {
+		name     string
+		spec     model.Checker
+		expected string
+		result   string
+		ok       bool
+	}{
+		{"exact same", model.Checker{}, "1 2\n3\n", "1 2\n3\n", true},
+		{"exact trailing spaces", model.Checker{Mode: ModeExact}, "1 2\n3\n", "1 2  \r\n3\n\n\n", true},
+		{"exact no final newline", model.Checker{Mode: ModeExact}, "1 2\n3\n", "1 2\n3", true},
+		{"exact inner spaces", model.Checker{Mode: ModeExact}, "1 2\n", "1  2\n", false},
+		{"exact extra line", model.Checker{Mode: ModeExact}, "1\n", "1\n2\n", false},
+		{"exact missing line", model.Checker{Mode: ModeExact}, "1\n2\n", "1\n", false},
+		{"tokens spacing", model.Checker{Mode: ModeTokens}, "1 2\n3\n", "1\n2 3", true},
+		{"tokens differ", model.Checker{Mode: ModeTokens}, "1 2 3", "1 2 4", false},
+		{"tokens extra", model.Checker{Mode: ModeTokens}, "1 2", "1 2 3", false},
+		{"tokens case", model.Checker{Mode: ModeTokens}, "Yes", "yes", false},
+		{"float default epsilon", model.Checker{Mode: ModeFloat}, "0.5", "0.5000001", true},
+		{"float beyond default epsilon", model.Checker{Mode: ModeFloat}, "0.5", "0.50001", false},
+		{"float absolute", model.Checker{Mode: ModeFloat, Epsilon: 1e-3}, "1.0", "1.0009", true},
+		{"float absolute beyond", model.Checker{Mode: ModeFloat, Epsilon: 1e-3}, "1.0", "1.0011", false},
+		{"float relative", model.Checker{Mode: ModeFloat, Epsilon: 1e-6}, "1000000000", "1000000900", true},
+		{"float relative beyond", model.Checker{Mode: ModeFloat, Epsilon: 1e-6}, "1000000000", "1000001100", false},
+		{"float exponent", model.Checker{Mode: ModeFloat}, "1e-3", "0.001", true},
+		{"float words", model.Checker{Mode: ModeFloat}, "answer 1.5", "answer 1.5000000001", true},
+		{"float word differs", model.Checker{Mode: ModeFloat}, "answer 1.5", "result 1.5", false},
+		{"float nan", model.Checker{Mode: ModeFloat}, "1.5", "nan", false},
+		{"yesno case", model.Checker{Mode: ModeYesNo}, "YES\nNo\n", "yes no", true},
+		{"yesno differs", model.Checker{Mode: ModeYesNo}, "YES", "NO", false},
+		{"unordered", model.Checker{Mode: ModeUnordered}, "1 2\n3 4\n5\n", "5\n3 4\n1 2\n", true},
+		{"unordered duplicates", model.Checker{Mode: ModeUnordered}, "1\n1\n2\n", "1\n2\n2\n", false},
+		{"unordered count", model.Checker{Mode: ModeUnordered}, "1\n2\n", "2\n", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := check(t, c.spec, c.expected, c.result)
+			if r.Ok != c.ok {
+				t.Errorf("expected ok=%v, got %+v", c.ok, r)
+			}
+			if !r.Ok && len(r.Message) == 0 {
+				t.Errorf("no message for wrong answer")
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, spec := range []model.Checker{{Mode: "fuzzy"}, {Mode: ModeExternal}} {
+		if _, err := New(spec, "."); err == nil {
+			t.Errorf("New(%+v) should fail", spec)
+		}
+	}
+	c, err := New(model.Checker{Mode: ModeExternal, Program: "check.sh"}, "/tmp/task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program := c.(externalChecker).program; program != "/tmp/task/check.sh" {
+		t.Errorf("relative program is resolved to %s", program)
+	}
+}
+
+/* Error of the shell exiting with the code */
+func exitError(t *testing.T, code string) error {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	return exec.Command("sh", "-c", "exit "+code).Run()
+}
+
+func TestInterpretExit(t *testing.T) {
+	cases := []struct {
+		name    string
+		code    string
+		ok      bool
+		failure bool
+	}{
+		{"ok", "0", true, false},
+		{"wrong answer", "1", false, false},
+		{"presentation error", "2", false, false},
+		{"checker failure", "3", false, true},
+		{"unknown code", "7", false, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := InterpretExit("checker", exitError(t, c.code), "message")
+			if c.failure {
+				if err == nil {
+					t.Fatalf("expected failure, got %+v", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Ok != c.ok || r.Message != "message" {
+				t.Errorf("expected ok=%v, got %+v", c.ok, r)
+			}
+		})
+	}
+	if _, err := InterpretExit("checker", exec.Command("/nonexistent/checker").Run(), ""); err == nil {
+		t.Errorf("failure to start should be an error")
+	}
+}
+
+func TestExternalChecker(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	dir := t.TempDir()
+	program := filepath.Join(dir, "check.sh")
+	/* accepts when the result equals the expected output, like testlib checkers */
+	script := "#!/bin/sh\ncmp -s \"$2\" \"$3\" && exit 0\necho differs\nexit 1\n"
+	if err := ioutil.WriteFile(program, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	spec := model.Checker{Mode: ModeExternal, Program: "check.sh"}
+	c, err := New(spec, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range []string{"42\n", "43\n"} {
+		expectedPath := filepath.Join(dir, "expected")
+		resultPath := filepath.Join(dir, "result")
+		ioutil.WriteFile(expectedPath, []byte("42\n"), 0644)
+		ioutil.WriteFile(resultPath, []byte(result), 0644)
+		r, err := c.Check(filepath.Join(dir, "input"), expectedPath, resultPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Ok != (result == "42\n") {
+			t.Errorf("result %q: got %+v", result, r)
+		}
+		if !r.Ok && r.Message != "differs" {
+			t.Errorf("message of checker is lost: %+v", r)
+		}
+	}
+}
@@ -2,11 +2,17 @@ package cmd
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
+	"github.com/mxwell/wac/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -31,7 +37,7 @@ func readBuildMethod(name string) *BuildMethod {
 	langName := subtree.GetString("Language")
 	language, ok := LanguageByName[langName]
 	if !ok {
-		panic(fmt.Errorf("Bad config: build method '%s' uses unknown language '%s'", name, langName))
+		fatal(ExitUsageError, "ERROR Bad config: build method '%s' uses unknown language '%s'\n", name, langName)
 	}
 	return &BuildMethod{language, subtree.GetString("Command")}
 }
@@ -47,8 +53,17 @@ func readConfig() {
 	}
 }
 
+/* Solution source given by --input, the same for all commands which build the solution */
 func getInput(method *BuildMethod) (string, error) {
-	name := InputName
+	return resolveSource(InputName, method)
+}
+
+func addInputFlag(cmd *cobra.Command, shorthand string) {
+	cmd.Flags().StringVarP(&InputName, "input", shorthand, "main.*", "Solution source file, extension could be given as \".*\" to use the one of build method language")
+}
+
+/* Source name may end with ".*" to use extension of the build method language */
+func resolveSource(name string, method *BuildMethod) (string, error) {
 	if strings.HasSuffix(name, ".*") {
 		name = name[:len(name)-1] + method.language.extension
 	}
@@ -75,59 +90,127 @@ func getCommand(method *BuildMethod, input, output string) *exec.Cmd {
 	return exec.Command(tokens[0], tokens[1:]...)
 }
 
+type BuildOutput struct {
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+/* Build input into output with the method, messages of compiler are kept in out */
+func buildProgram(method *BuildMethod, input string, output string, out *BuildOutput) error {
+	if input == output {
+		return fmt.Errorf("equal input and output - '%s'", input)
+	}
+	command := getCommand(method, input, output)
+	if command == nil {
+		return nil
+	}
+	command.Stdout = &out.stdout
+	command.Stderr = &out.stderr
+	if err := command.Run(); err != nil {
+		os.Remove(stampPath(output))
+		return err
+	}
+	if err := saveStamp(method, input, output); err != nil {
+		log.Printf("WARN failed to save build stamp: %s\n", err)
+	}
+	return nil
+}
+
+func printBuildOutput(out *BuildOutput) {
+	if out.stdout.Len() > 0 {
+		fmt.Println("<stdout>")
+		out.stdout.WriteTo(os.Stdout)
+	}
+	if out.stderr.Len() > 0 {
+		fmt.Println("<stderr>")
+		out.stderr.WriteTo(os.Stdout)
+	}
+}
+
+/* Name of the file keeping fingerprint of the last successful build of output */
+func stampPath(output string) string {
+	return filepath.Join(filepath.Dir(output), "."+filepath.Base(output)+".stamp")
+}
+
+/* Fingerprint of the build: command of the method and contents of the source */
+func buildFingerprint(method *BuildMethod, input string) (string, error) {
+	source, err := ioutil.ReadFile(input)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(method.command))
+	hash.Write([]byte{0})
+	hash.Write(source)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/* True if output was built from the same source with the same method */
+func isUpToDate(method *BuildMethod, input string, output string) bool {
+	if !util.PathExists(output) {
+		return false
+	}
+	stamp, err := ioutil.ReadFile(stampPath(output))
+	if err != nil {
+		return false
+	}
+	fingerprint, err := buildFingerprint(method, input)
+	return err == nil && string(stamp) == fingerprint
+}
+
+func saveStamp(method *BuildMethod, input string, output string) error {
+	fingerprint, err := buildFingerprint(method, input)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(stampPath(output), []byte(fingerprint), 0644)
+}
+
+/* Find build method by name, empty name stands for the default method */
+func findBuildMethod(name string) (*BuildMethod, error) {
+	if len(name) == 0 {
+		name = viper.GetString("DefaultBuildMethod")
+	}
+	method, ok := MethodByName[name]
+	if !ok {
+		return nil, fmt.Errorf("build method '%s' not found in config", name)
+	}
+	return method, nil
+}
+
 var buildCmd = &cobra.Command{
 	Use:   "build [BUILD_METHOD]",
 	Short: "Build solution",
 	Long:  `Build solution from a source file into an executable using BUILD_METHOD, if applicable. Default build method is set in config under DefaultBuildMethod.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		readConfig()
-		methodName := viper.GetString("DefaultBuildMethod")
+		methodName := ""
 		if len(args) == 1 {
 			methodName = args[0]
 		}
-		method, ok := MethodByName[methodName]
-		if !ok {
-			fmt.Printf("ERROR build method '%s' not found in config\n", methodName)
-			return
+		method, err := findBuildMethod(methodName)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 		if len(OutputName) == 0 {
 			OutputName = viper.GetString("SolutionName")
 		}
 		input, err := getInput(method)
 		if err != nil {
-			fmt.Printf("ERROR bad input: %s", err)
-			return
-		}
-		if input == OutputName {
-			fmt.Printf("ERROR equal input and output - '%s'", input)
-			return
-		}
-		command := getCommand(method, input, OutputName)
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		if command != nil {
-			command.Stdout = &stdout
-			command.Stderr = &stderr
-			err = command.Run()
+			fatal(ExitUsageError, "ERROR bad input: %s\n", err)
 		}
+		var out BuildOutput
+		err = buildProgram(method, input, OutputName, &out)
+		printBuildOutput(&out)
 		if err != nil {
-			fmt.Printf("ERROR Build failed: %s\n", err)
-		} else {
-			fmt.Println("OK")
-		}
-		if stdout.Len() > 0 {
-			fmt.Println("<stdout>")
-			stdout.WriteTo(os.Stdout)
-		}
-		if stderr.Len() > 0 {
-			fmt.Println("<stderr>")
-			stderr.WriteTo(os.Stdout)
+			fatal(ExitCompileError, "ERROR Build failed: %s\n", err)
 		}
+		fmt.Println("OK")
 	},
 }
 
 func init() {
-	buildCmd.Flags().StringVarP(&InputName, "input", "i", "main.*", "Build input file")
+	addInputFlag(buildCmd, "i")
 	buildCmd.Flags().StringVarP(&OutputName, "output", "o", "", "Build output file (default is set in config under SolutionName)")
 	RootCmd.AddCommand(buildCmd)
 }
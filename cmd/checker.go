@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mxwell/wac/checker"
+	"github.com/mxwell/wac/model"
+	"github.com/spf13/cobra"
+)
+
+var checkerEpsilon float64
+var checkerProgram string
+
+func describeChecker(spec *model.Checker) string {
+	switch spec.Mode {
+	case "":
+		return checker.ModeExact + " (default)"
+	case checker.ModeFloat:
+		return fmt.Sprintf("%s, epsilon %g", spec.Mode, spec.Epsilon)
+	case checker.ModeExternal:
+		return fmt.Sprintf("%s, program %s", spec.Mode, spec.Program)
+	}
+	return spec.Mode
+}
+
+var checkerCmd = &cobra.Command{
+	Use:   "checker [MODE]",
+	Short: "Show or set checker of task",
+	Long: `Show checker of current task or set it to MODE. The checker is stored in contest metadata and used by run unless another one is given in its flags.
+
+Modes: ` + strings.Join(checker.Modes, ", ") + `.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			fatal(ExitUsageError, "ERROR at most one argument is expected")
+		}
+		contest, err := model.LocateContest()
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		taskToken, err := model.DetermineCurrentTask(contest)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
+		}
+		task, _ := contest.Tasks[taskToken]
+		if len(args) == 0 {
+			fmt.Printf("Checker of task %s: %s\n", taskToken, describeChecker(&task.Checker))
+			return
+		}
+		spec := model.Checker{Mode: args[0]}
+		if spec.Mode == checker.ModeFloat {
+			spec.Epsilon = checkerEpsilon
+		}
+		if spec.Mode == checker.ModeExternal {
+			spec.Program = checkerProgram
+		}
+		/* validate the spec before saving */
+		if _, err := checker.New(spec, filepath.Join(contest.RootDir, taskToken)); err != nil {
+			fatal(ExitUsageError, "ERROR bad checker: %s", err)
+		}
+		task.Checker = spec
+		contest.Tasks[taskToken] = task
+		err = model.SaveContest(contest)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR failed to save contest metadata.")
+		}
+		fmt.Printf("Checker of task %s is set to %s\n", taskToken, describeChecker(&task.Checker))
+	},
+}
+
+func init() {
+	checkerCmd.Flags().Float64VarP(&checkerEpsilon, "epsilon", "e", checker.DefaultEpsilon, "Absolute or relative error allowed by float checker")
+	checkerCmd.Flags().StringVarP(&checkerProgram, "program", "p", "", "Checker program for external checker, path is relative to task directory")
+	RootCmd.AddCommand(checkerCmd)
+}
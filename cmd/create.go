@@ -2,11 +2,11 @@ package cmd
 
 import (
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 
+	"github.com/mxwell/wac/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -62,27 +62,7 @@ func checkDestination(template Template, destination string) (string, error) {
 
 func copyTemplate(template Template, destination string) error {
 	source := viper.GetString("TemplatesDir") + "/" + fullName(template)
-
-	input, err := os.Open(source)
-	if err != nil {
-		return err
-	}
-	defer input.Close()
-
-	output, err := os.Create(destination)
-	if err != nil {
-		return err
-	}
-	defer output.Close()
-
-	_, err = io.Copy(output, input)
-	cerr := output.Close()
-
-	if err != nil {
-		return err
-	}
-
-	return cerr
+	return util.CopyFile(source, destination)
 }
 
 var Filename string
@@ -100,17 +80,14 @@ Templates are stored alongside the configuration file.`,
 		}
 		template, err := findTemplate(template_name)
 		if err != nil {
-			fmt.Printf("ERROR %s\n", err)
-			return
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 		destination, err := checkDestination(template, Filename)
 		if err != nil {
-			fmt.Printf("ERROR %s\n", err)
-			return
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 		if err := copyTemplate(template, destination); err != nil {
-			fmt.Printf("ERROR when copying template '%s' into '%s': %s\n", template.name, destination, err)
-			return
+			fatal(ExitUsageError, "ERROR when copying template '%s' into '%s': %s\n", template.name, destination, err)
 		}
 		fmt.Printf("Created %s\n", destination)
 	},
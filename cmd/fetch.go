@@ -6,14 +6,19 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/mxwell/wac/model"
 	"github.com/mxwell/wac/platforms"
 	"github.com/mxwell/wac/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var fetchAll bool
+var FetchJobs int
+var FetchArchives bool
 
 func saveStringToFile(s *string, path string) error {
 	f, err := os.Create(path)
@@ -28,96 +33,202 @@ func saveStringToFile(s *string, path string) error {
 	return nil
 }
 
+/* Test case of every input line, one per line, the file of a previous fetch is removed if lines aren't marked */
+func saveLineGroups(groups []int, path string) error {
+	if len(groups) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var b strings.Builder
+	for _, group := range groups {
+		b.WriteString(strconv.Itoa(group))
+		b.WriteString("\n")
+	}
+	s := b.String()
+	return saveStringToFile(&s, path)
+}
+
 // The function fetches samples from a platform, saves them into task directory
-// and adds info on the samples into contest struct
-func fetchForTask(platform model.Platform, contest *model.Contest, token string) error {
+// and returns the task updated with info on the samples
+func fetchForTask(platform model.Platform, contest *model.Contest, task model.Task) (model.Task, int, error) {
+	token := task.Token
 	task_path := filepath.Join(contest.RootDir, token)
 	if _, err := os.Stat(task_path); os.IsNotExist(err) {
 		err = os.MkdirAll(task_path, 0777)
 		if err != nil {
-			return fmt.Errorf("can't create a subdir '%s' for task: %s", task_path, err)
+			return task, 0, fmt.Errorf("can't create a subdir '%s' for task: %s", task_path, err)
 		}
 	}
-	log.Printf("Processing task '%s' ...\n", token)
-	task, ok := contest.Tasks[token]
-	if !ok {
-		return fmt.Errorf("no task with token '%s' in contest '%s'", token, contest.Name)
-	}
-	tests, err := platform.GetTests(&task)
+	/* the platform fills the copy, so the task is left intact on failure */
+	updated := task
+	updated.TestTokens = append([]string(nil), task.TestTokens...)
+	tests, err := platform.GetTests(&updated)
 	if err != nil {
-		return fmt.Errorf("unable to get tests for task with token '%s': %s", token, err)
+		return task, 0, fmt.Errorf("unable to get tests for task with token '%s': %s", token, err)
+	}
+	if archive, ok := platform.(model.TestArchive); ok && FetchArchives {
+		/* samples are useful without the archive, e.g. when the task isn't solved yet */
+		if archived, err := archive.GetTestArchive(&updated); err == nil {
+			tests = append(tests, archived...)
+		} else {
+			log.Printf("[%s] WARN unable to get test archive: %s\n", token, err)
+		}
+	}
+	if task.TestGroups != nil {
+		updated.TestGroups = make(map[string][]string)
+		for group, tokens := range task.TestGroups {
+			updated.TestGroups[group] = append([]string(nil), tokens...)
+		}
 	}
 	for _, test := range tests {
 		sample_path := filepath.Join(task_path, test.Token)
-		if util.ContainsString(&task.TestTokens, test.Token) {
-			log.Printf("Test '%s' was already present, re-writing...", test.Token)
+		if len(test.Group) > 0 {
+			if updated.TestGroups == nil {
+				updated.TestGroups = make(map[string][]string)
+			}
+			tokens := updated.TestGroups[test.Group]
+			if !util.ContainsString(&tokens, test.Token) {
+				updated.TestGroups[test.Group] = append(tokens, test.Token)
+			}
+		} else if util.ContainsString(&updated.TestTokens, test.Token) {
+			log.Printf("[%s] Test '%s' was already present, re-writing...", token, test.Token)
 		} else {
-			task.TestTokens = append(task.TestTokens, test.Token)
+			updated.TestTokens = append(updated.TestTokens, test.Token)
 		}
 		input_path := sample_path + ".in"
 		output_path := sample_path + ".out"
 		err = saveStringToFile(&test.Input, input_path)
 		if err != nil {
-			return err
+			return task, 0, err
 		}
 		err = saveStringToFile(&test.Output, output_path)
 		if err != nil {
-			return err
+			return task, 0, err
+		}
+		if err := saveLineGroups(test.InputLineGroups, sample_path+".groups"); err != nil {
+			return task, 0, err
 		}
-		log.Printf("%s saved to %s and %s\n", test.Token, filepath.Base(input_path), filepath.Base(output_path))
 	}
-	contest.Tasks[token] = task
-	return nil
+	return updated, len(tests), nil
+}
+
+type fetchResult struct {
+	task  model.Task
+	count int
+	err   error
+}
+
+/* Fetch tasks by a bounded pool of workers, each result is reported as soon as it's ready */
+func fetchTasks(platform model.Platform, contest *model.Contest, tokens []string, jobs int) []fetchResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+	queue := make(chan int)
+	done := make(chan int)
+	results := make([]fetchResult, len(tokens))
+	for w := 0; w < jobs && w < len(tokens); w++ {
+		go func() {
+			for i := range queue {
+				task, count, err := fetchForTask(platform, contest, contest.Tasks[tokens[i]])
+				results[i] = fetchResult{task, count, err}
+				done <- i
+			}
+		}()
+	}
+	go func() {
+		for i := range tokens {
+			queue <- i
+		}
+		close(queue)
+	}()
+	for range tokens {
+		i := <-done
+		if results[i].err != nil {
+			log.Printf("[%s] ERROR can't fetch task: %s\n", tokens[i], results[i].err)
+		} else {
+			log.Printf("[%s] %d test(s) saved\n", tokens[i], results[i].count)
+		}
+	}
+	return results
+}
+
+/* Tokens of all tasks ordered lexicographically */
+func sortedTokens(contest *model.Contest) []string {
+	tokens := make([]string, 0, len(contest.Tasks))
+	for token, _ := range contest.Tasks {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+/* Fetch tests of the tasks and save contest metadata, the result is the exit code */
+func fetchAndSave(platform model.Platform, contest *model.Contest, tokens []string) int {
+	exitCode := ExitOk
+	for _, result := range fetchTasks(platform, contest, tokens, FetchJobs) {
+		if result.err != nil {
+			exitCode = ExitPlatformError
+			continue
+		}
+		contest.Tasks[result.task.Token] = result.task
+	}
+
+	/* saved once, even if some tasks have failed */
+	err := model.SaveContest(contest)
+	if err != nil {
+		fatal(ExitUsageError, "ERROR failed to save contest metadata.")
+	}
+	return exitCode
 }
 
 var fetchCmd = &cobra.Command{
 	Use:   "fetch",
 	Short: "Fetch tests for task(s)",
-	Long:  `Fetch sample tests from the platform for current task or for all tasks in the contest.`,
+	Long: `Fetch sample tests from the platform for current task or for all tasks in the contest. When the platform marks the test case of every input line of a sample, like Codeforces does, the marks are saved to TOKEN.groups, one per line.
+
+With --all, statements are saved too, if FetchStatements is enabled in config, see 'wac statement'.
+
+With --archive, full sets of tests are downloaded too, where the platform gives them out. They are saved as separate groups, which are run with 'wac run --group'.
+
+Tasks are fetched concurrently. Requests have a timeout and are retried with exponential backoff, requests to one host are spread in time.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		contest, err := model.LocateContest()
 		if err != nil {
-			log.Fatalf("ERROR %s\n", err)
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 
 		platform := platforms.FindPlatform(contest.Link)
 		if platform == nil {
-			log.Fatalf("ERROR unable to find platform for contest url %s\n", contest.Link)
+			fatal(ExitUsageError, "ERROR unable to find platform for contest url %s\n", contest.Link)
 		}
 
+		var tokens []string
 		if fetchAll {
-			/* Order tokens lexicographically */
-			tokens := make([]string, 0, len(contest.Tasks))
-			for token, _ := range contest.Tasks {
-				tokens = append(tokens, token)
-			}
-			sort.Strings(tokens)
-			/* Process tasks ordered by token */
-			for _, token := range tokens {
-				err := fetchForTask(platform, contest, token)
-				if err != nil {
-					log.Printf("ERROR can't fetch task: %s\n", err)
-				}
-			}
+			tokens = sortedTokens(contest)
 		} else {
 			token, err := model.DetermineCurrentTask(contest)
 			if err != nil {
-				log.Fatalf("ERROR can't determine current task: %s\n", err)
-			}
-			err = fetchForTask(platform, contest, token)
-			if err != nil {
-				log.Fatalf("ERROR can't fetch task: %s\n", err)
+				fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
 			}
+			tokens = []string{token}
 		}
 
-		err = model.SaveContest(contest)
-		if err != nil {
-			log.Fatalf("ERROR failed to save contest metadata.")
+		exitCode := fetchAndSave(platform, contest, tokens)
+		if fetchAll && viper.GetBool("FetchStatements") {
+			saveStatements(platform, contest, tokens)
 		}
+		os.Exit(exitCode)
 	},
 }
 
 func init() {
 	fetchCmd.Flags().BoolVarP(&fetchAll, "all", "a", false, "Fetch tests for all tasks")
+	fetchCmd.Flags().IntVarP(&FetchJobs, "jobs", "j", 4, "Number of tasks fetched concurrently")
+	fetchCmd.Flags().BoolVarP(&FetchArchives, "archive", "", false, "Also download full test archives where the platform provides them, like hidden tests of solved CSES tasks")
+	fetchCmd.Flags().DurationVarP(&util.RequestTimeout, "timeout", "", util.RequestTimeout, "Timeout of a single request, 0 for no timeout")
+	fetchCmd.Flags().IntVarP(&util.RequestRetries, "retries", "", util.RequestRetries, "Number of retries of a failed request")
+	fetchCmd.Flags().DurationVarP(&util.HostInterval, "host-interval", "", util.HostInterval, "Minimal interval between requests to one host")
 	RootCmd.AddCommand(fetchCmd)
 }
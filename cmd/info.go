@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -31,6 +30,24 @@ func getRelativePath(src string, dest string) string {
 	return filepath.Join(prefix, branch)
 }
 
+func describeLimits(task *model.Task) string {
+	var parts []string
+	if task.TimeLimit > 0 {
+		parts = append(parts, task.TimeLimit.String())
+	}
+	if task.MemoryLimit > 0 {
+		parts = append(parts, fmt.Sprintf("%d MB", task.MemoryLimit))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeStream(file string, standard string) string {
+	if len(file) == 0 {
+		return standard
+	}
+	return file
+}
+
 var infoCmd = &cobra.Command{
 	Use:   "info",
 	Short: "Show info about current tree",
@@ -38,15 +55,18 @@ var infoCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		contest, err := model.LocateContest()
 		if err != nil {
-			log.Fatalf("ERROR %s\n", err)
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 		fmt.Printf("Contest: %s -- %s\n", contest.Name, contest.Link)
+		if !contest.StartTime.IsZero() {
+			fmt.Printf("Start: %s\n", contest.StartTime.Local().Format("2006-01-02 15:04:05 MST"))
+		}
 		if len(contest.Tasks) == 0 {
 			fmt.Println("No tasks.")
 		} else {
 			wd, err := os.Getwd()
 			if err != nil {
-				log.Fatalf("ERROR %s\n", err)
+				fatal(ExitUsageError, "ERROR %s\n", err)
 			}
 			fmt.Println("Tasks:")
 			/* Order tokens lexicographically */
@@ -67,6 +87,12 @@ var infoCmd = &cobra.Command{
 					token_s = "  [" + token + "]"
 				}
 				fmt.Printf("\n%s %s -- %s\n\tpath:  %s\n", token_s, task.Name, task.Link, rel_path)
+				if limits := describeLimits(&task); len(limits) > 0 {
+					fmt.Printf("\tlimits: %s\n", limits)
+				}
+				if len(task.InputFile) > 0 || len(task.OutputFile) > 0 {
+					fmt.Printf("\tfiles: %s / %s\n", describeStream(task.InputFile, "stdin"), describeStream(task.OutputFile, "stdout"))
+				}
 				if len(task.TestTokens) > 0 {
 					fmt.Printf("\ttests:")
 					for _, testToken := range task.TestTokens {
@@ -74,6 +100,14 @@ var infoCmd = &cobra.Command{
 					}
 					fmt.Println()
 				}
+				groups := make([]string, 0, len(task.TestGroups))
+				for group := range task.TestGroups {
+					groups = append(groups, group)
+				}
+				sort.Strings(groups)
+				for _, group := range groups {
+					fmt.Printf("\tgroup %s: %d test(s)\n", group, len(task.TestGroups[group]))
+				}
 			}
 		}
 	},
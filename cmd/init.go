@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/mxwell/wac/model"
 	"github.com/mxwell/wac/platforms"
@@ -25,6 +27,59 @@ func determineRootDirectory(args []string) (string, error) {
 	}
 }
 
+var InitWait bool
+var InitWaitTimeout time.Duration
+
+/* Polling interval right after the start, it's doubled up to the maximum */
+const FirstPollInterval = time.Second
+const MaxPollInterval = 15 * time.Second
+
+func formatCountdown(d time.Duration) string {
+	/* round up, so zero is shown only at the start */
+	d = (d + time.Second - 1).Truncate(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second))
+}
+
+/* Show countdown to the start, then poll the platform until tasks of the contest appear */
+func waitForContest(platform model.Platform, link string, root_dirname string) (*model.Contest, error) {
+	/* without start time there is no countdown, tasks are polled right away */
+	if start, err := platform.GetStartTime(link); err != nil {
+		log.Printf("WARN can't get start time: %s, waiting for tasks...\n", err)
+	} else {
+		fmt.Printf("Contest starts at %s\n", start.Local().Format("2006-01-02 15:04:05 MST"))
+		for left := time.Until(start); left > 0; left = time.Until(start) {
+			fmt.Printf("\rStarts in %s ", formatCountdown(left))
+			/* wake up when the next second is due */
+			step := left % time.Second
+			if step == 0 {
+				step = time.Second
+			}
+			time.Sleep(step)
+		}
+		fmt.Println("\rContest has started, waiting for tasks...")
+	}
+	deadline := time.Now().Add(InitWaitTimeout)
+	interval := FirstPollInterval
+	for attempt := 1; ; attempt++ {
+		contest, err := platform.GetContest(link, root_dirname)
+		if err == nil && len(contest.Tasks) > 0 {
+			return contest, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no tasks yet")
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("tasks are not available within %s: %s", InitWaitTimeout, err)
+		}
+		log.Printf("Attempt %d: %s, next one in %s\n", attempt, err, interval)
+		time.Sleep(interval)
+		interval *= 2
+		if interval > MaxPollInterval {
+			interval = MaxPollInterval
+		}
+	}
+}
+
 func initContestDirectory(contest *model.Contest) error {
 	/* Compose path for metadata and check it's not taken */
 	root_file := model.GetRootFile(contest)
@@ -57,39 +112,52 @@ var initCmd = &cobra.Command{
 	Short: "Initialize contest in directory",
 	Long: `Initialize DIRECTORY with metadata of the contest specified by URL. Current directory is used when DIRECTORY is omitted. Non-existing directory will be created.
 
-URLs of regular Codeforces rounds are supported.`,
+Supported URLs:
+  Codeforces: contests, gyms and group contests, like https://codeforces.com/gym/<id>,
+    and single problems, like https://codeforces.com/problemset/problem/<contest>/<problem>,
+    which make a contest of one task;
+  AtCoder: https://atcoder.jp/contests/<id> and legacy https://<id>.contest.atcoder.jp;
+  Kattis: contests, like https://open.kattis.com/contests/<id>, and single problems, like https://open.kattis.com/problems/<id>;
+  CSES: the whole problem set https://cses.fi/problemset/, its section, like "cses:Dynamic Programming"
+    or https://cses.fi/problemset/#dynamic-programming, and single tasks, like https://cses.fi/problemset/task/<id>.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 || len(args) > 2 {
-			fmt.Printf("wrong number of arguments - %d\n", len(args))
-			return
+			fatal(ExitUsageError, "wrong number of arguments - %d\n", len(args))
 		}
 
 		root_dirname, err := determineRootDirectory(args)
 		if err != nil {
-			fmt.Printf("ERROR can't determine working directory: %s\n", err)
-			return
+			fatal(ExitUsageError, "ERROR can't determine working directory: %s\n", err)
 		}
 
 		platform := platforms.FindPlatform(args[0])
 		if platform == nil {
-			fmt.Printf("ERROR unable to find platform for url %s\n", args[0])
-			return
+			fatal(ExitUsageError, "ERROR unable to find platform for url %s\n", args[0])
+		}
+		var contest *model.Contest
+		if InitWait {
+			contest, err = waitForContest(platform, args[0], root_dirname)
+		} else {
+			contest, err = platform.GetContest(args[0], root_dirname)
 		}
-		contest, err := platform.GetContest(args[0], root_dirname)
 		if err != nil {
-			fmt.Printf("ERROR can't fetch contest: %s\n", err)
-			return
+			fatal(ExitPlatformError, "ERROR can't fetch contest: %s\n", err)
 		}
 
 		if err := initContestDirectory(contest); err != nil {
-			fmt.Printf("ERROR can't init contest directory: %s\n", err)
-			return
+			fatal(ExitUsageError, "ERROR can't init contest directory: %s\n", err)
 		}
 
 		fmt.Printf("Root directory: %s\n", contest.RootDir)
+		if InitWait {
+			os.Exit(fetchAndSave(platform, contest, sortedTokens(contest)))
+		}
 	},
 }
 
 func init() {
+	initCmd.Flags().BoolVarP(&InitWait, "wait", "", false, "Wait for the start of the contest, then initialize the directory and fetch tests of all tasks; tasks are polled right away if the start time is unknown, like for Codeforces group contests")
+	initCmd.Flags().DurationVarP(&InitWaitTimeout, "wait-timeout", "", 15*time.Minute, "How long to wait for tasks after the start")
+	initCmd.Flags().IntVarP(&FetchJobs, "jobs", "j", 4, "Number of tasks fetched concurrently after waiting")
 	RootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mxwell/wac/checker"
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+)
+
+/* Interactor looked up in task directory when the task is interactive */
+const DefaultInteractorName = "interactor"
+
+/* How long the interactor may keep running after the solution has finished, when there is no time limit */
+const InteractorGracePeriod = time.Second
+
+var InteractorName string
+var WriteTranscript bool
+
+/* Exchange between solution and interactor, written line by line with direction marks */
+type transcript struct {
+	mutex sync.Mutex
+	out   io.Writer
+}
+
+func (t *transcript) writeLine(prefix string, line []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	io.WriteString(t.out, prefix)
+	t.out.Write(line)
+}
+
+/* One direction of the exchange, accumulates data until the end of line */
+type transcriptStream struct {
+	log     *transcript
+	prefix  string
+	partial []byte
+}
+
+func (s *transcriptStream) append(p []byte) {
+	s.partial = append(s.partial, p...)
+	for {
+		eol := bytes.IndexByte(s.partial, '\n')
+		if eol < 0 {
+			break
+		}
+		s.log.writeLine(s.prefix, s.partial[:eol+1])
+		s.partial = s.partial[eol+1:]
+	}
+}
+
+func (s *transcriptStream) flush() {
+	if len(s.partial) > 0 {
+		s.log.writeLine(s.prefix, append(s.partial, '\n'))
+		s.partial = nil
+	}
+}
+
+/* Forwards data into the pipe and copies it into transcript. Errors of the pipe are ignored, as the peer may exit early. */
+type teeWriter struct {
+	pipe   *os.File
+	stream *transcriptStream
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	w.stream.append(p)
+	w.pipe.Write(p)
+	return len(p), nil
+}
+
+/*
+ * Run the solution cross-wired with the interactor, which is invoked as
+ * `interactor input output [answer]`. Limits are applied to the solution, the
+ * time limit is applied to the interactor too. Result is nil when the
+ * interactor is killed before it gives the verdict.
+ */
+func doInteractiveRun(interactorPath string, inputPath string, resultPath string, answerPath string, transcriptPath string, limits Limits) (*RunStats, *checker.Result, error) {
+	toSolutionR, toSolutionW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create pipe: %s", err)
+	}
+	defer toSolutionR.Close()
+	defer toSolutionW.Close()
+	toInteractorR, toInteractorW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create pipe: %s", err)
+	}
+	defer toInteractorR.Close()
+	defer toInteractorW.Close()
+
+	args := []string{inputPath, resultPath}
+	if len(answerPath) > 0 {
+		args = append(args, answerPath)
+	}
+	interactor := exec.Command(interactorPath, args...)
+	interactor.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var interactorStderr bytes.Buffer
+	interactor.Stdin = toInteractorR
+	interactor.Stderr = &interactorStderr
+
+	stats := &RunStats{}
+	solution := getSolutionCommand()
+	solution.Stdin = toSolutionR
+	solution.Stderr = &stats.stderr
+
+	var streams []*transcriptStream
+	if len(transcriptPath) > 0 {
+		transcriptFile, err := os.Create(transcriptPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create transcript file: %s", err)
+		}
+		defer transcriptFile.Close()
+		log := &transcript{out: transcriptFile}
+		solutionStream := &transcriptStream{log: log, prefix: "> "}
+		interactorStream := &transcriptStream{log: log, prefix: "< "}
+		streams = append(streams, solutionStream, interactorStream)
+		solution.Stdout = &teeWriter{toInteractorW, solutionStream}
+		interactor.Stdout = &teeWriter{toSolutionW, interactorStream}
+	} else {
+		solution.Stdout = toInteractorW
+		interactor.Stdout = toSolutionW
+	}
+
+	err = interactor.Start()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start interactor: %s", err)
+	}
+	/* both processes are under the same wall-clock timer */
+	var deadline <-chan time.Time
+	if limits.time > 0 {
+		timer := time.NewTimer(limits.time)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	/* the solution should get EOF or EPIPE once the interactor is gone */
+	toInteractorR.Close()
+	interactorDone := make(chan error, 1)
+	go func() {
+		err := interactor.Wait()
+		toSolutionW.Close()
+		interactorDone <- err
+	}()
+
+	stats, err = execute(solution, stats, limits)
+	toSolutionR.Close()
+	toInteractorW.Close()
+	if err != nil {
+		killProcessGroup(interactor)
+		<-interactorDone
+		return nil, nil, err
+	}
+
+	if deadline == nil {
+		deadline = time.After(InteractorGracePeriod)
+	}
+	var interactorErr error
+	finished := true
+	select {
+	case interactorErr = <-interactorDone:
+	case <-deadline:
+		finished = false
+		killProcessGroup(interactor)
+		<-interactorDone
+	}
+	/* don't leave behind processes spawned by the interactor */
+	killProcessGroup(interactor)
+	for _, stream := range streams {
+		stream.flush()
+	}
+	if !finished {
+		return stats, nil, nil
+	}
+	result, err := checker.InterpretExit("interactor", interactorErr, strings.TrimSpace(interactorStderr.String()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return stats, result, nil
+}
+
+func runInteractiveTest(w io.Writer, taskDir string, testToken string, limits Limits, interactorPath string) (*Outcome, error) {
+	testPathPrefix := filepath.Join(taskDir, testToken)
+	inputPath := testPathPrefix + ".in"
+	answerPath := testPathPrefix + ".out"
+	resultPath := testPathPrefix + ".result"
+	if !util.PathExists(answerPath) {
+		answerPath = ""
+	}
+	transcriptPath := ""
+	if WriteTranscript {
+		transcriptPath = testPathPrefix + ".transcript"
+	}
+
+	stats, result, err := doInteractiveRun(interactorPath, inputPath, resultPath, answerPath, transcriptPath, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run solution with interactor: %s", err)
+	}
+	if len(stats.failure) > 0 {
+		printStderr(w, &stats.stderr, StderrExcerptLines)
+	} else {
+		printStderr(w, &stats.stderr, 0)
+	}
+	outcome := &Outcome{stats.wall_time, stats.cpu_time, stats.peak_memory, VerdictOk, ""}
+	switch {
+	case stats.memory_exceeded:
+		outcome.verdict = VerdictMemoryLimit
+	case stats.timed_out:
+		outcome.verdict = VerdictTimeLimit
+	case result == nil:
+		outcome.verdict = VerdictTimeLimit
+		outcome.details = "interactor didn't finish in time"
+	/* the solution is killed by SIGPIPE when it writes after the interactor has rejected it */
+	case len(stats.failure) > 0 && !(stats.failure == "SIGPIPE" && !result.Ok):
+		outcome.verdict = VerdictRuntimeError
+		outcome.details = stats.failure
+	case !result.Ok:
+		outcome.verdict = VerdictDiffers
+		outcome.details = result.Message
+	}
+	return outcome, nil
+}
+
+/* Path to the interactor, or empty string when the task should be run as a regular one */
+func determineInteractor(task *model.Task, taskDir string) (string, error) {
+	if len(InteractorName) > 0 {
+		return filepath.Abs(InteractorName)
+	}
+	if !task.Interactive {
+		return "", nil
+	}
+	path := filepath.Join(taskDir, DefaultInteractorName)
+	if !util.PathExists(path) {
+		return "", fmt.Errorf("task is interactive, but interactor is not found at %s (use --interactor to specify one)", path)
+	}
+	return path, nil
+}
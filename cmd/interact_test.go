@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/* Interactive test with solution and interactor given as shell scripts */
+func runInteractiveScripts(t *testing.T, solution string, interactor string, limits Limits) *Outcome {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	dir := t.TempDir()
+	solutionPath := filepath.Join(dir, "solution.sh")
+	interactorPath := filepath.Join(dir, "interactor")
+	files := map[string]string{
+		solutionPath:                     solution,
+		interactorPath:                   "#!/bin/sh\n" + interactor,
+		filepath.Join(dir, "sample1.in"): "",
+	}
+	for path, content := range files {
+		if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	savedMethod, savedName := TheMethod, SolutionName
+	defer func() { TheMethod, SolutionName = savedMethod, savedName }()
+	TheMethod = &ExecMethod{"sh $OUTPUT"}
+	SolutionName = solutionPath
+	outcome, err := runInteractiveTest(ioutil.Discard, dir, "sample1", limits, interactorPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outcome
+}
+
+func TestInteractiveVerdicts(t *testing.T) {
+	limits := Limits{time: 2 * time.Second}
+	cases := []struct {
+		name       string
+		solution   string
+		interactor string
+		verdict    Verdict
+	}{
+		{"accepted", "read x; echo $((x + 1))", "echo 41; read y; [ \"$y\" = 42 ]", VerdictOk},
+		{"wrong answer", "read x; echo $x", "echo 41; read y; [ \"$y\" = 42 ] || exit 1", VerdictDiffers},
+		{"crash before rejection", "read x; exit 3", "echo 41; read y || exit 1", VerdictRuntimeError},
+		{"hung interactor", "read x; echo $x", "echo 41; read y; sleep 10", VerdictTimeLimit},
+		{"hung solution", "sleep 10", "echo 41; read y", VerdictTimeLimit},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start := time.Now()
+			outcome := runInteractiveScripts(t, c.solution, c.interactor, limits)
+			if outcome.verdict != c.verdict {
+				t.Errorf("expected %s, got %s (%s)", c.verdict, outcome.verdict, outcome.details)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("test took %s, processes weren't killed on time", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var Jobs int
+
+/* Runs a single test, details are reported to w */
+type testRunner func(w io.Writer, testToken string) (*Outcome, error)
+
+type TestResult struct {
+	token   string
+	outcome *Outcome
+}
+
+type testReport struct {
+	output  bytes.Buffer
+	outcome *Outcome
+	err     error
+}
+
+func countVerdicts(results []TestResult) map[Verdict]int {
+	counts := make(map[Verdict]int)
+	for _, result := range results {
+		counts[result.outcome.verdict]++
+	}
+	return counts
+}
+
+/* Print the report of the test, returns whether next tests should be run */
+func reportTest(out io.Writer, testToken string, report *testReport, results *[]TestResult) (bool, error) {
+	report.output.WriteTo(out)
+	if report.err != nil {
+		return false, fmt.Errorf("failed to run test '%s': %s", testToken, report.err)
+	}
+	printOutcome(out, report.outcome)
+	*results = append(*results, TestResult{testToken, report.outcome})
+	return report.outcome.verdict == VerdictOk || KeepGoing, nil
+}
+
+/*
+ * Run tests using the given number of workers. Results are reported to out in order
+ * of tokens. Unless KeepGoing is set, no more tests are started after the first failure.
+ */
+func runTests(out io.Writer, tokens []string, jobs int, runTest testRunner) ([]TestResult, error) {
+	var results []TestResult
+	if jobs <= 1 {
+		for _, testToken := range tokens {
+			fmt.Fprintf(out, "[%s] ... ", testToken)
+			report := &testReport{}
+			report.outcome, report.err = runTest(out, testToken)
+			proceed, err := reportTest(out, testToken, report, &results)
+			if err != nil || !proceed {
+				return results, err
+			}
+		}
+		return results, nil
+	}
+
+	reports := make([]chan *testReport, len(tokens))
+	for i := range reports {
+		reports[i] = make(chan *testReport, 1)
+	}
+	indices := make(chan int)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				report := &testReport{}
+				report.outcome, report.err = runTest(&report.output, tokens[i])
+				reports[i] <- report
+			}
+		}()
+	}
+	go func() {
+		defer close(indices)
+		for i := range tokens {
+			select {
+			case indices <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var err error
+	for i, testToken := range tokens {
+		var proceed bool
+		report := <-reports[i]
+		fmt.Fprintf(out, "[%s] ... ", testToken)
+		proceed, err = reportTest(out, testToken, report, &results)
+		if err != nil || !proceed {
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+	return results, err
+}
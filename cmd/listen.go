@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+	"github.com/spf13/cobra"
+)
+
+/* one of the ports Competitive Companion sends problems to by default */
+const DefaultListenPort = 10043
+
+var ListenPort int
+
+/* Problem as sent by Competitive Companion */
+type companionProblem struct {
+	Name        string
+	Group       string
+	Url         string
+	Interactive bool
+	MemoryLimit uint64 /* megabytes */
+	TimeLimit   int64  /* milliseconds */
+	Tests       []struct {
+		Input  string
+		Output string
+	}
+	Input  companionStream
+	Output companionStream
+}
+
+type companionStream struct {
+	Type     string
+	FileName string
+}
+
+/* Problems go to the contest of the working directory, or to a directory named after their group */
+type listener struct {
+	mutex   sync.Mutex
+	current *model.Contest
+	baseDir string
+}
+
+/* Tests of the problem are saved as sampleN, they are replaced when the problem is sent again */
+var sampleTokenRegexp = regexp.MustCompile(`^sample[0-9]+$`)
+
+/* Names are usually like "A. Name" or "B - Name" */
+var problemNameRegexp = regexp.MustCompile(`^([A-Za-z0-9]{1,3})\s*[.:-]\s+(.+)$`)
+
+/* Token and name of the task for the problem */
+func problemToken(problem *companionProblem) (string, string) {
+	if match := problemNameRegexp.FindStringSubmatch(problem.Name); match != nil {
+		return strings.ToLower(match[1]), match[2]
+	}
+	if u, err := url.Parse(problem.Url); err == nil {
+		if token := util.Slug(path.Base(u.Path)); len(token) > 0 && token != "." {
+			return token, problem.Name
+		}
+	}
+	if token := util.Slug(problem.Name); len(token) > 0 {
+		return token, problem.Name
+	}
+	return "task", problem.Name
+}
+
+/* File of the task is created in the scratch directory of the run, so it must be a plain name */
+func checkStreamFile(stream *companionStream) error {
+	if stream.Type != "file" {
+		return nil
+	}
+	name := stream.FileName
+	if name != filepath.Base(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("bad file name '%s'", name)
+	}
+	return nil
+}
+
+/*
+ * Competitive Companion sends problems from the extension, so requests have no Origin
+ * or the origin of the extension. Web pages shouldn't be able to add tasks.
+ */
+func allowedOrigin(origin string) bool {
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(u.Scheme, "-extension")
+}
+
+func (l *listener) contestFor(problem *companionProblem) (*model.Contest, error) {
+	if l.current != nil {
+		return l.current, nil
+	}
+	name := util.Slug(problem.Group)
+	if len(name) == 0 {
+		name = "contest"
+	}
+	rootDir := filepath.Join(l.baseDir, name)
+	contest := &model.Contest{Name: problem.Group, Tasks: make(map[string]model.Task), RootDir: rootDir}
+	if loaded, err := model.LoadContest(model.GetRootFile(contest)); err == nil {
+		return loaded, nil
+	}
+	if err := initContestDirectory(contest); err != nil {
+		return nil, err
+	}
+	log.Printf("New contest '%s' in %s\n", contest.Name, contest.RootDir)
+	return contest, nil
+}
+
+/* Add the problem as a task with its tests, the task with the same URL is replaced */
+func (l *listener) addProblem(problem *companionProblem) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	contest, err := l.contestFor(problem)
+	if err != nil {
+		return err
+	}
+	base, name := problemToken(problem)
+	token := base
+	for n := 2; ; n++ {
+		task, ok := contest.Tasks[token]
+		if !ok || task.Link == problem.Url {
+			break
+		}
+		token = base + strconv.Itoa(n)
+	}
+	task := model.Task{
+		Link:        problem.Url,
+		Name:        name,
+		Token:       token,
+		TestTokens:  make([]string, 0),
+		TimeLimit:   time.Duration(problem.TimeLimit) * time.Millisecond,
+		MemoryLimit: problem.MemoryLimit,
+		Interactive: problem.Interactive,
+	}
+	var userTokens, staleTokens []string
+	if old, ok := contest.Tasks[token]; ok {
+		/* settings and tests added by user are kept */
+		task.Checker = old.Checker
+		for _, testToken := range old.TestTokens {
+			if sampleTokenRegexp.MatchString(testToken) {
+				staleTokens = append(staleTokens, testToken)
+			} else {
+				userTokens = append(userTokens, testToken)
+			}
+		}
+	}
+	if problem.Input.Type == "file" {
+		task.InputFile = problem.Input.FileName
+	}
+	if problem.Output.Type == "file" {
+		task.OutputFile = problem.Output.FileName
+	}
+	taskDir := filepath.Join(contest.RootDir, token)
+	if err := os.MkdirAll(taskDir, 0777); err != nil {
+		return fmt.Errorf("can't create a subdir '%s' for task: %s", taskDir, err)
+	}
+	for i, test := range problem.Tests {
+		testToken := fmt.Sprintf("sample%d", i+1)
+		prefix := filepath.Join(taskDir, testToken)
+		if err := saveStringToFile(&test.Input, prefix+".in"); err != nil {
+			return err
+		}
+		if err := saveStringToFile(&test.Output, prefix+".out"); err != nil {
+			return err
+		}
+		/* Competitive Companion doesn't mark test cases of lines, unlike fetch */
+		if err := saveLineGroups(nil, prefix+".groups"); err != nil {
+			return err
+		}
+		task.TestTokens = append(task.TestTokens, testToken)
+	}
+	for _, testToken := range staleTokens {
+		if util.ContainsString(&task.TestTokens, testToken) {
+			continue
+		}
+		for _, ext := range []string{".in", ".out", ".groups"} {
+			if err := os.Remove(filepath.Join(taskDir, testToken+ext)); err != nil && !os.IsNotExist(err) {
+				log.Printf("WARN failed to remove stale test: %s\n", err)
+			}
+		}
+	}
+	task.TestTokens = append(task.TestTokens, userTokens...)
+	contest.Tasks[token] = task
+	if err := model.SaveContest(contest); err != nil {
+		return fmt.Errorf("failed to save contest metadata: %s", err)
+	}
+	log.Printf("[%s] %s -- %d test(s) saved to %s\n", token, name, len(problem.Tests), taskDir)
+	return nil
+}
+
+func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "problems are expected to be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	if origin := r.Header.Get("Origin"); !allowedOrigin(origin) {
+		log.Printf("ERROR request from %s is rejected\n", origin)
+		http.Error(w, "problems are accepted from the browser extension only", http.StatusForbidden)
+		return
+	}
+	var problem companionProblem
+	if err := json.NewDecoder(r.Body).Decode(&problem); err != nil {
+		log.Printf("ERROR bad problem: %s\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, stream := range []*companionStream{&problem.Input, &problem.Output} {
+		if err := checkStreamFile(stream); err != nil {
+			log.Printf("ERROR bad problem '%s': %s\n", problem.Name, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := l.addProblem(&problem); err != nil {
+		log.Printf("ERROR can't add problem '%s': %s\n", problem.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+var listenCmd = &cobra.Command{
+	Use:   "listen",
+	Short: "Receive problems from Competitive Companion",
+	Long: `Run HTTP server receiving problems from Competitive Companion browser extension, which parses problems of many judges. Every problem becomes a task with its tests saved as sampleN. When the problem is sent again, its samples are replaced, while tests added by user are kept.
+
+When started inside a contest directory, tasks are added to that contest. Otherwise a contest directory named after the group of the problem (usually the contest name) is created in the working directory or reused.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		wd, err := os.Getwd()
+		if err != nil {
+			fatal(ExitUsageError, "ERROR can't determine working directory: %s\n", err)
+		}
+		l := &listener{baseDir: wd}
+		if contest, err := model.LocateContest(); err == nil {
+			l.current = contest
+			log.Printf("Tasks are added to contest '%s' in %s\n", contest.Name, contest.RootDir)
+		}
+		address := fmt.Sprintf("127.0.0.1:%d", ListenPort)
+		log.Printf("Listening on %s, press Ctrl+C to stop\n", address)
+		if err := http.ListenAndServe(address, l); err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+	},
+}
+
+func init() {
+	listenCmd.Flags().IntVarP(&ListenPort, "port", "p", DefaultListenPort, "Port to listen on, should be among ports of Competitive Companion")
+	RootCmd.AddCommand(listenCmd)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+)
+
+func companionTests(outputs ...string) []struct{ Input, Output string } {
+	tests := make([]struct{ Input, Output string }, 0)
+	for _, output := range outputs {
+		tests = append(tests, struct{ Input, Output string }{"in", output})
+	}
+	return tests
+}
+
+func TestResentProblemReplacesSamples(t *testing.T) {
+	l := &listener{baseDir: t.TempDir()}
+	problem := companionProblem{Name: "A. Sum", Group: "Round 1", Url: "https://example.com/a"}
+	problem.Tests = companionTests("1", "2", "3")
+	if err := l.addProblem(&problem); err != nil {
+		t.Fatal(err)
+	}
+	contestDir := filepath.Join(l.baseDir, "round-1")
+	taskDir := filepath.Join(contestDir, "a")
+	/* test added by user, like with addtest */
+	if err := ioutil.WriteFile(filepath.Join(taskDir, "my.in"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	l.current = loadContest(t, contestDir)
+	task := l.current.Tasks["a"]
+	task.TestTokens = append(task.TestTokens, "my")
+	l.current.Tasks["a"] = task
+
+	problem.Tests = companionTests("one")
+	if err := l.addProblem(&problem); err != nil {
+		t.Fatal(err)
+	}
+	tokens := loadContest(t, contestDir).Tasks["a"].TestTokens
+	if expected := []string{"sample1", "my"}; !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expected tokens %v, got %v", expected, tokens)
+	}
+	if output, _ := ioutil.ReadFile(filepath.Join(taskDir, "sample1.out")); string(output) != "one" {
+		t.Errorf("sample is not rewritten: %q", output)
+	}
+	for _, name := range []string{"sample2.in", "sample2.out", "sample3.in", "sample3.out"} {
+		if util.PathExists(filepath.Join(taskDir, name)) {
+			t.Errorf("stale %s is left", name)
+		}
+	}
+	if !util.PathExists(filepath.Join(taskDir, "my.in")) {
+		t.Errorf("test of user is removed")
+	}
+}
+
+func TestProblemToken(t *testing.T) {
+	cases := []struct {
+		name  string
+		url   string
+		token string
+		title string
+	}{
+		{"A. Sum", "https://codeforces.com/contest/1/problem/A", "a", "Sum"},
+		{"B - Digits", "https://atcoder.jp/contests/abc1/tasks/abc1_b", "b", "Digits"},
+		{"Weird Algorithm", "https://cses.fi/problemset/task/1068", "1068", "Weird Algorithm"},
+		{"Hello, World!", "", "hello-world", "Hello, World!"},
+		{"", "", "task", ""},
+	}
+	for _, c := range cases {
+		token, title := problemToken(&companionProblem{Name: c.name, Url: c.url})
+		if token != c.token || title != c.title {
+			t.Errorf("%q: expected %s %q, got %s %q", c.name, c.token, c.title, token, title)
+		}
+	}
+}
+
+func TestServeRejectsUnsafeRequests(t *testing.T) {
+	l := &listener{baseDir: t.TempDir()}
+	problem := func(inputFile string) string {
+		return `{"name":"A. Sum","group":"Round 1","url":"https://example.com/a","tests":[{"input":"1","output":"1"}],` +
+			`"input":{"type":"file","fileName":"` + inputFile + `"},"output":{"type":"stdout"}}`
+	}
+	cases := []struct {
+		origin string
+		body   string
+		status int
+	}{
+		{"https://evil.example.com", problem("sum.in"), http.StatusForbidden},
+		{"null", problem("sum.in"), http.StatusForbidden},
+		{"", problem("../../.bashrc"), http.StatusBadRequest},
+		{"", problem("/etc/passwd"), http.StatusBadRequest},
+		{"", problem(".."), http.StatusBadRequest},
+		{"", problem("sum.in"), http.StatusOK},
+		{"chrome-extension://abcdef", problem("sum.in"), http.StatusOK},
+		{"moz-extension://0a1b2c", problem("sum.in"), http.StatusOK},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+		if len(c.origin) > 0 {
+			r.Header.Set("Origin", c.origin)
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%q from %q: expected status %d, got %d", c.body, c.origin, c.status, w.Code)
+		}
+	}
+	contest := loadContest(t, filepath.Join(l.baseDir, "round-1"))
+	if inputFile := contest.Tasks["a"].InputFile; inputFile != "sum.in" {
+		t.Errorf("unexpected input file %q", inputFile)
+	}
+}
+
+func loadContest(t *testing.T, dir string) *model.Contest {
+	t.Helper()
+	contest, err := model.LoadContest(model.GetRootFile(&model.Contest{RootDir: dir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contest
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mxwell/wac/credentials"
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/platforms"
+	"github.com/spf13/cobra"
+)
+
+var SaveCredentials bool
+
+/* Platform given by name in the only argument */
+func findPlatformArg(args []string) model.Platform {
+	if len(args) != 1 {
+		fatal(ExitUsageError, "ERROR platform name is required, one of: %s\n", strings.Join(platforms.PlatformNames(), ", "))
+	}
+	platform := platforms.FindPlatformByName(args[0])
+	if platform == nil {
+		fatal(ExitUsageError, "ERROR unknown platform %s, expected one of: %s\n", args[0], strings.Join(platforms.PlatformNames(), ", "))
+	}
+	return platform
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login PLATFORM",
+	Short: "Log in to platform",
+	Long: `Ask for credentials of PLATFORM and save them in the encrypted file in config directory. Platforms which keep a session also log in and save session cookies, other commands reuse the session and log in again only when it expires.
+
+Commands which need credentials look for them in environment variables WAC_<PLATFORM>_<KEY> (like WAC_ATCODER_USERNAME) first, then in the encrypted file, and ask for them as the last resort. Passphrase of the file is taken from ` + credentials.PassphraseVariable + ` if it is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		platform := findPlatformArg(args)
+		name := platform.Name()
+		fields := platform.Credentials()
+		if len(fields) == 0 {
+			fatal(ExitUsageError, "ERROR platform %s doesn't require credentials\n", name)
+		}
+		values, err := credentials.Prompt(fields)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		if sessionPlatform, ok := platform.(model.SessionPlatform); ok {
+			if err := sessionPlatform.Login(values); err != nil {
+				fatal(ExitPlatformError, "ERROR failed to log in to %s: %s\n", name, err)
+			}
+			fmt.Printf("Logged in to %s\n", name)
+		}
+		if SaveCredentials {
+			if err := credentials.Save(name, values); err != nil {
+				fatal(ExitUsageError, "ERROR failed to save credentials: %s\n", err)
+			}
+			fmt.Printf("Credentials of %s are saved\n", name)
+		}
+	},
+}
+
+func init() {
+	loginCmd.Flags().BoolVarP(&SaveCredentials, "save", "s", true, "Save credentials in the encrypted file")
+	RootCmd.AddCommand(loginCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mxwell/wac/credentials"
+	"github.com/mxwell/wac/model"
+	"github.com/spf13/cobra"
+)
+
+var ForgetCredentials bool
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout PLATFORM",
+	Short: "Forget session of platform",
+	Long:  `Delete session cookies of PLATFORM saved by login, and optionally its saved credentials.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		platform := findPlatformArg(args)
+		name := platform.Name()
+		if sessionPlatform, ok := platform.(model.SessionPlatform); ok {
+			if err := sessionPlatform.Logout(); err != nil {
+				fatal(ExitUsageError, "ERROR failed to delete session of %s: %s\n", name, err)
+			}
+			fmt.Printf("Logged out from %s\n", name)
+		}
+		if ForgetCredentials {
+			if err := credentials.Forget(name); err != nil {
+				fatal(ExitUsageError, "ERROR failed to delete credentials: %s\n", err)
+			}
+			fmt.Printf("Credentials of %s are deleted\n", name)
+		}
+	},
+}
+
+func init() {
+	logoutCmd.Flags().BoolVarP(&ForgetCredentials, "forget", "f", false, "Delete saved credentials too")
+	RootCmd.AddCommand(logoutCmd)
+}
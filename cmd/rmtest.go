@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/mxwell/wac/model"
 	"github.com/spf13/cobra"
@@ -14,17 +13,17 @@ var rmtestCmd = &cobra.Command{
 	Long:  `Remove test case specified by TOKEN from a task. The command will remove files with names TOKEN.in and TOKEN.out .`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) != 1 {
-			log.Fatalf("ERROR single argument is required for the command")
+			fatal(ExitUsageError, "ERROR single argument is required for the command")
 		}
 		testToken := args[0]
 
 		contest, err := model.LocateContest()
 		if err != nil {
-			log.Fatalf("ERROR %s\n", err)
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 		taskToken, err := model.DetermineCurrentTask(contest)
 		if err != nil {
-			log.Fatalf("ERROR can't determine current task: %s\n", err)
+			fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
 		}
 		task, _ := contest.Tasks[taskToken]
 		pos := -1
@@ -36,13 +35,13 @@ var rmtestCmd = &cobra.Command{
 			}
 		}
 		if pos == -1 {
-			log.Fatalf("ERROR test %s not found", testToken)
+			fatal(ExitUsageError, "ERROR test %s not found", testToken)
 		}
 		task.TestTokens = append(tokens[:pos], tokens[pos+1:]...)
 		contest.Tasks[taskToken] = task
 		err = model.SaveContest(contest)
 		if err != nil {
-			log.Fatalf("ERROR failed to save contest metadata.")
+			fatal(ExitUsageError, "ERROR failed to save contest metadata.")
 		}
 		fmt.Printf("Test %s is removed from task %s\n", testToken, taskToken)
 	},
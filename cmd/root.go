@@ -16,13 +16,37 @@ var RootCmd = &cobra.Command{
 	Long: `WAC is a CLI tool that helps contestants of programming contests
 to write, build and test code of solutions.
 
-Version 0.1`,
+Version 0.1
+
+Exit codes:
+  0  success, all tests passed
+  1  wrong answer on some test
+  2  runtime error, time or memory limit exceeded on some test
+  3  compile error
+  4  usage or configuration error
+  5  network or platform error`,
+}
+
+/* Exit codes shared by all commands */
+const (
+	ExitOk             = 0
+	ExitWrongAnswer    = 1
+	ExitRuntimeFailure = 2
+	ExitCompileError   = 3
+	ExitUsageError     = 4
+	ExitPlatformError  = 5
+)
+
+/* Print the message and exit with the code */
+func fatal(code int, format string, a ...interface{}) {
+	log.Printf(format, a...)
+	os.Exit(code)
 }
 
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(-1)
+		os.Exit(ExitUsageError)
 	}
 }
 
@@ -33,12 +57,17 @@ func init() {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	defer func() {
+		if r := recover(); r != nil {
+			fatal(ExitUsageError, "ERROR %s\n", r)
+		}
+	}()
 	util.CheckConfiguration()
 	viper.SetConfigName("wac") // name of config file (without extension)
 	viper.AddConfigPath(util.GetDefaultLocation())
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("Fatal error config file: %s \n", err))
+		fatal(ExitUsageError, "ERROR Fatal error config file: %s\n", err)
 	}
 }
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
@@ -10,10 +9,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/mxwell/wac/checker"
 	"github.com/mxwell/wac/model"
 	"github.com/mxwell/wac/util"
 	"github.com/spf13/cobra"
@@ -24,9 +28,108 @@ type ExecMethod struct {
 	command string
 }
 
+type Verdict int
+
+const (
+	VerdictOk Verdict = iota
+	VerdictDiffers
+	VerdictTimeLimit
+	VerdictMemoryLimit
+	VerdictRuntimeError
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case VerdictOk:
+		return "Ok"
+	case VerdictDiffers:
+		return "Differs"
+	case VerdictTimeLimit:
+		return "TLE"
+	case VerdictMemoryLimit:
+		return "MLE"
+	case VerdictRuntimeError:
+		return "RE"
+	}
+	return "Unknown"
+}
+
+/* Short name of the verdict as used by judges */
+func (v Verdict) Code() string {
+	if v == VerdictDiffers {
+		return "WA"
+	}
+	return v.String()
+}
+
+/* Exit code of run when the verdict is the worst one */
+func (v Verdict) ExitCode() int {
+	switch v {
+	case VerdictOk:
+		return ExitOk
+	case VerdictDiffers:
+		return ExitWrongAnswer
+	}
+	return ExitRuntimeFailure
+}
+
+func exitCodeOf(results []TestResult) int {
+	code := ExitOk
+	for _, result := range results {
+		if c := result.outcome.verdict.ExitCode(); c > code {
+			code = c
+		}
+	}
+	return code
+}
+
+/* Order of verdicts in the summary of a run */
+var summaryVerdicts = []Verdict{VerdictOk, VerdictDiffers, VerdictRuntimeError, VerdictTimeLimit, VerdictMemoryLimit}
+
+/* Names of signals that usually terminate crashed solutions */
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
+
+/* Zero value of any field means no limit */
+type Limits struct {
+	time   time.Duration
+	memory uint64 // bytes
+}
+
+type RunStats struct {
+	wall_time       time.Duration
+	cpu_time        time.Duration
+	peak_memory     uint64 // bytes
+	timed_out       bool
+	memory_exceeded bool
+	/* non-empty when the solution has crashed or exited with non-zero code */
+	failure string
+	stderr  bytes.Buffer
+}
+
 type Outcome struct {
-	exec_time      time.Duration
-	output_differs bool
+	exec_time   time.Duration
+	cpu_time    time.Duration
+	peak_memory uint64
+	verdict     Verdict
+	details     string
 }
 
 var ExecMethodByName = map[string]*ExecMethod{}
@@ -37,7 +140,21 @@ var UseStdStreams bool
 var KeepGoing bool
 var BeSilent bool
 var StackSize uint64
+var TimeLimit time.Duration
+var MemoryLimit uint64
+var CheckerSpec model.Checker
+var TestGroup string
 var knownStackSize uint64 = 0
+var stackSizeMutex sync.Mutex
+
+/* used when neither --time-limit nor the task metadata specify a limit */
+const DefaultTimeLimit = 5 * time.Second
+
+/* how often resident memory of the solution is checked against the limit */
+const MemoryPollInterval = 10 * time.Millisecond
+
+/* number of stderr lines shown for a crashed solution */
+const StderrExcerptLines = 10
 
 func readExecConfig() {
 	methods := viper.GetStringMap("RunMethods")
@@ -48,8 +165,29 @@ func readExecConfig() {
 	}
 }
 
+/* Choose exec method and solution name from flags or config */
+func setupExecMethod() error {
+	readExecConfig()
+	if len(ExecMethodName) == 0 {
+		ExecMethodName = viper.GetString("DefaultRunMethod")
+	}
+	var ok bool
+	if TheMethod, ok = ExecMethodByName[ExecMethodName]; !ok {
+		return fmt.Errorf("exec method '%s' not found in config", ExecMethodName)
+	}
+	if len(SolutionName) == 0 {
+		SolutionName = viper.GetString("SolutionName")
+	}
+	return nil
+}
+
 func getSolutionCommand() *exec.Cmd {
-	commandLine := strings.Replace(TheMethod.command, "$OUTPUT", SolutionName, -1)
+	return getProgramCommand(TheMethod, SolutionName)
+}
+
+/* Command to execute built program with the given name */
+func getProgramCommand(method *ExecMethod, name string) *exec.Cmd {
+	commandLine := strings.Replace(method.command, "$OUTPUT", name, -1)
 	tokens := strings.Split(commandLine, " ")
 	if len(tokens) == 1 {
 		return exec.Command(tokens[0])
@@ -61,6 +199,8 @@ func getSolutionCommand() *exec.Cmd {
 }
 
 func setStackSize(target uint64) error {
+	stackSizeMutex.Lock()
+	defer stackSizeMutex.Unlock()
 	if knownStackSize >= target {
 		return nil
 	}
@@ -87,14 +227,126 @@ func setStackSize(target uint64) error {
 	return nil
 }
 
-func doRun(inputPath string, resultPath string) (error, time.Duration) {
-	var stderr bytes.Buffer
+/* kill the whole process group of the command, so children of the solution die too */
+func killProcessGroup(command *exec.Cmd) {
+	syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+}
+
+/* Current resident memory of the process in bytes */
+func residentMemory(pid int) (uint64, error) {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected format of statm: %s", b)
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+/* Run the solution on input from file to result file, empty paths mean standard streams */
+func doRun(inputPath string, resultPath string, limits Limits) (*RunStats, error) {
+	return runProgram(getSolutionCommand(), inputPath, resultPath, limits)
+}
 
-	command := getSolutionCommand()
+/* Files of the task used by the solution instead of standard streams */
+type FileIO struct {
+	input  string
+	output string
+}
+
+func taskFiles(task *model.Task) FileIO {
+	return FileIO{task.InputFile, task.OutputFile}
+}
+
+/* Run the solution on the test, putting input to and taking output from files if the task needs it */
+func doRunTask(inputPath string, resultPath string, limits Limits, files FileIO) (*RunStats, error) {
+	return doRunProgramTask(getSolutionCommand(), inputPath, resultPath, limits, files)
+}
+
+/* Same as doRunTask, but for any program, like brute force */
+func doRunProgramTask(command *exec.Cmd, inputPath string, resultPath string, limits Limits, files FileIO) (*RunStats, error) {
+	if len(files.input) == 0 && len(files.output) == 0 {
+		return runProgram(command, inputPath, resultPath, limits)
+	}
+	return doRunWithFiles(command, inputPath, resultPath, limits, files)
+}
+
+/* Command started in another directory, files of the working directory are referred by absolute paths */
+func commandIn(command *exec.Cmd, dir string) (*exec.Cmd, error) {
+	for i, arg := range command.Args {
+		if util.PathExists(arg) {
+			abs, err := filepath.Abs(arg)
+			if err != nil {
+				return nil, err
+			}
+			command.Args[i] = abs
+		}
+	}
+	if !filepath.IsAbs(command.Path) {
+		abs, err := filepath.Abs(command.Path)
+		if err != nil {
+			return nil, err
+		}
+		command.Path = abs
+	}
+	command.Dir = dir
+	return command, nil
+}
+
+/*
+ * Run the program in a scratch directory, so concurrent runs don't share files.
+ * The test input is put there under the name expected by the task, and the output
+ * file is copied to the result path afterwards.
+ */
+func doRunWithFiles(command *exec.Cmd, inputPath string, resultPath string, limits Limits, files FileIO) (*RunStats, error) {
+	dir, err := ioutil.TempDir("", "wac-run")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	command, err = commandIn(command, dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files.input) > 0 {
+		if err := util.CopyFile(inputPath, filepath.Join(dir, files.input)); err != nil {
+			return nil, fmt.Errorf("failed to put test input to %s: %s", files.input, err)
+		}
+		inputPath = os.DevNull
+	}
+	runResultPath := resultPath
+	if len(files.output) > 0 {
+		runResultPath = os.DevNull
+	}
+	stats, err := runProgram(command, inputPath, runResultPath, limits)
+	if err != nil {
+		return nil, err
+	}
+	if len(files.output) > 0 {
+		err := util.CopyFile(filepath.Join(dir, files.output), resultPath)
+		if os.IsNotExist(err) {
+			/* missing output file is the same as empty output */
+			err = ioutil.WriteFile(resultPath, nil, 0666)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to take output from %s: %s", files.output, err)
+		}
+	}
+	return stats, nil
+}
+
+func runProgram(command *exec.Cmd, inputPath string, resultPath string, limits Limits) (*RunStats, error) {
+	stats := &RunStats{}
 	if len(inputPath) > 0 {
 		inputReader, err := os.Open(inputPath)
 		if err != nil {
-			return fmt.Errorf("failed to open test input: %s", err), 0
+			return nil, fmt.Errorf("failed to open test input: %s", err)
 		}
 		defer inputReader.Close()
 		command.Stdin = inputReader
@@ -104,207 +356,370 @@ func doRun(inputPath string, resultPath string) (error, time.Duration) {
 	if len(resultPath) > 0 {
 		resultWriter, err := os.OpenFile(resultPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 		if err != nil {
-			return fmt.Errorf("failed to open file to write output: %s", err), 0
+			return nil, fmt.Errorf("failed to open file to write output: %s", err)
 		}
 		defer resultWriter.Close()
 		command.Stdout = resultWriter
 	} else {
 		command.Stdout = os.Stdout
 	}
-	command.Stderr = &stderr
+	command.Stderr = &stats.stderr
+	return execute(command, stats, limits)
+}
+
+/*
+ * Run the command with prepared streams within the given limits. When a limit
+ * is exceeded, the command is killed along with all its children.
+ *
+ * Memory limit is checked by polling resident memory rather than by RLIMIT_AS,
+ * because binaries built with sanitizers reserve terabytes of address space.
+ */
+func execute(command *exec.Cmd, stats *RunStats, limits Limits) (*RunStats, error) {
+	limited := limits.time > 0 || limits.memory > 0
+	if limited {
+		/* a separate process group lets us kill the whole process tree */
+		command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
 
 	err := setStackSize(StackSize)
 	if err != nil {
-		return fmt.Errorf("failed to increase stack size: %s", err), 0
+		return nil, fmt.Errorf("failed to increase stack size: %s", err)
 	}
 
 	start := time.Now()
-	err = command.Run()
-	elapsed := time.Since(start)
-
-	if stderr.Len() > 0 {
-		fmt.Println("<stderr>")
-		stderr.WriteTo(os.Stdout)
+	err = command.Start()
+	if err != nil {
+		return nil, err
 	}
-
-	return err, elapsed
-}
-
-func readWholeLine(r *bufio.Reader) (string, error) {
-	result := make([]byte, 0)
-	for {
-		if bytes, isPrefix, err := r.ReadLine(); err == nil {
-			result = append(result, bytes...)
-			if !isPrefix {
-				break
+	waitResult := make(chan error, 1)
+	go func() {
+		waitResult <- command.Wait()
+	}()
+	var timeout <-chan time.Time
+	if limits.time > 0 {
+		timer := time.NewTimer(limits.time)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var memoryPoll <-chan time.Time
+	if limits.memory > 0 {
+		ticker := time.NewTicker(MemoryPollInterval)
+		defer ticker.Stop()
+		memoryPoll = ticker.C
+	}
+	running := true
+	for running {
+		select {
+		case err = <-waitResult:
+			running = false
+		case <-timeout:
+			stats.timed_out = true
+			killProcessGroup(command)
+			err = <-waitResult
+			running = false
+		case <-memoryPoll:
+			rss, rssErr := residentMemory(command.Process.Pid)
+			if rssErr == nil && rss > limits.memory {
+				stats.memory_exceeded = true
+				killProcessGroup(command)
+				err = <-waitResult
+				running = false
 			}
+		}
+	}
+	stats.wall_time = time.Since(start)
+	if limited {
+		/* don't leave behind processes spawned by the solution */
+		killProcessGroup(command)
+	}
+	if command.ProcessState != nil {
+		stats.cpu_time = command.ProcessState.UserTime() + command.ProcessState.SystemTime()
+		if usage, ok := command.ProcessState.SysUsage().(*syscall.Rusage); ok {
+			/* ru_maxrss is measured in kilobytes */
+			stats.peak_memory = uint64(usage.Maxrss) * 1024
+		}
+	}
+	if limits.time > 0 && stats.cpu_time > limits.time {
+		stats.timed_out = true
+	}
+	if limits.memory > 0 && stats.peak_memory > limits.memory {
+		stats.memory_exceeded = true
+	}
+
+	if stats.timed_out || stats.memory_exceeded {
+		return stats, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok && status.Signaled() {
+			stats.failure = signalName(status.Signal())
 		} else {
-			return "", err
+			stats.failure = fmt.Sprintf("exit code %d", exitErr.ExitCode())
 		}
+		return stats, nil
 	}
-	return strings.TrimRight(string(result), " "), nil
+	return stats, err
 }
 
-/* true if result is different from what was expected */
-func checkOutput(expectedPath string, resultPath string) (bool, error) {
-	a0, err := os.Open(expectedPath)
-	if err != nil {
-		return true, fmt.Errorf("failed to open file with expected output: %s", err)
+/* Print captured stderr, limited to maxLines lines unless maxLines is 0 */
+func printStderr(w io.Writer, stderr *bytes.Buffer, maxLines int) {
+	if stderr.Len() == 0 {
+		return
 	}
-	defer a0.Close()
-	a := bufio.NewReader(a0)
-
-	b0, err := os.Open(resultPath)
-	if err != nil {
-		return true, fmt.Errorf("failed to open file with solution output: %s", err)
-	}
-	defer b0.Close()
-	b := bufio.NewReader(b0)
-
-	adone := false
-	bdone := false
-	for !adone && !bdone {
-		aline, aerr := readWholeLine(a)
-		bline, berr := readWholeLine(b)
-		if aerr == io.EOF {
-			adone = true
-			aerr = nil
-		}
-		if berr == io.EOF {
-			bdone = true
-			berr = nil
-		}
-		if aerr != nil {
-			return true, fmt.Errorf("error when reading from '%s': %s", expectedPath, aerr)
-		}
-		if berr != nil {
-			return true, fmt.Errorf("error when reading from '%s': %s", resultPath, berr)
-		}
-		if aline != bline {
-			return true, nil
-		}
+	fmt.Fprintln(w, "<stderr>")
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	if maxLines > 0 && len(lines) > maxLines {
+		fmt.Fprintln(w, strings.Join(lines[:maxLines], "\n"))
+		fmt.Fprintf(w, "... (%d more lines)\n", len(lines)-maxLines)
+		return
 	}
-	return false, nil
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
 }
 
-func printFile(path string) error {
+func printFile(w io.Writer, path string) error {
 	b, err := ioutil.ReadFile(path)
 	if err == nil {
-		fmt.Printf("%s", b)
+		fmt.Fprintf(w, "%s", b)
 	}
 	return err
 }
 
-func runSingleTest(taskDir string, testToken string) (*Outcome, error) {
+/* Run the solution on a test, details are reported to w */
+func runSingleTest(w io.Writer, taskDir string, testToken string, limits Limits, files FileIO, check checker.Checker) (*Outcome, error) {
 	testPathPrefix := filepath.Join(taskDir, testToken)
 	inputPath := testPathPrefix + ".in"
 	outputPath := testPathPrefix + ".out"
 	resultPath := testPathPrefix + ".result"
 
-	err, elapsed := doRun(inputPath, resultPath)
+	stats, err := doRunTask(inputPath, resultPath, limits, files)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run solution: %s", err)
 	}
+	if len(stats.failure) > 0 {
+		printStderr(w, &stats.stderr, StderrExcerptLines)
+	} else {
+		printStderr(w, &stats.stderr, 0)
+	}
+	if stats.memory_exceeded {
+		return &Outcome{stats.wall_time, stats.cpu_time, stats.peak_memory, VerdictMemoryLimit, ""}, nil
+	}
+	if stats.timed_out {
+		return &Outcome{stats.wall_time, stats.cpu_time, stats.peak_memory, VerdictTimeLimit, ""}, nil
+	}
+	if len(stats.failure) > 0 {
+		return &Outcome{stats.wall_time, stats.cpu_time, stats.peak_memory, VerdictRuntimeError, stats.failure}, nil
+	}
 
-	diff, err := checkOutput(outputPath, resultPath)
+	checkResult, err := check.Check(inputPath, outputPath, resultPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check output: %s", err)
 	}
+	diff := !checkResult.Ok
 	if diff && !BeSilent {
-		fmt.Printf("\n== EXPECTED ==\n")
-		err = printFile(outputPath)
+		fmt.Fprintf(w, "\n== EXPECTED ==\n")
+		err = printFile(w, outputPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to report difference: %s", err)
 		}
-		fmt.Printf("\n== RESULT ==\n")
-		err = printFile(resultPath)
+		fmt.Fprintf(w, "\n== RESULT ==\n")
+		err = printFile(w, resultPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to report difference: %s", err)
 		}
-		fmt.Printf("\n============\n")
+		fmt.Fprintf(w, "\n============\n")
+	}
+	verdict := VerdictOk
+	if diff {
+		verdict = VerdictDiffers
+	}
+	return &Outcome{stats.wall_time, stats.cpu_time, stats.peak_memory, verdict, checkResult.Message}, nil
+}
+
+/* Limits from command line have priority over the ones from task metadata */
+func determineLimits(task *model.Task) Limits {
+	limits := Limits{DefaultTimeLimit, MemoryLimit * 1024 * 1024}
+	if TimeLimit > 0 {
+		limits.time = TimeLimit
+	} else if task.TimeLimit > 0 {
+		limits.time = task.TimeLimit
+	}
+	if MemoryLimit == 0 {
+		limits.memory = task.MemoryLimit * 1024 * 1024
+	}
+	return limits
+}
+
+/* Checker from command line has priority over the one from task metadata */
+func determineChecker(task *model.Task, taskDir string) (checker.Checker, error) {
+	spec := task.Checker
+	if len(CheckerSpec.Mode) > 0 {
+		spec = CheckerSpec
+	}
+	return checker.New(spec, taskDir)
+}
+
+func formatMemory(bytes uint64) string {
+	return fmt.Sprintf("%.1fMB", float64(bytes)/(1024*1024))
+}
+
+func printOutcome(w io.Writer, outc *Outcome) {
+	verdict := outc.verdict.String()
+	if len(outc.details) > 0 {
+		verdict += " (" + outc.details + ")"
+	}
+	fmt.Fprintf(w, "%s -- %dms, cpu %dms, %s\n", verdict, int(outc.exec_time/time.Millisecond), int(outc.cpu_time/time.Millisecond), formatMemory(outc.peak_memory))
+}
+
+func printSummary(counts map[Verdict]int) {
+	parts := make([]string, 0, len(summaryVerdicts))
+	for _, verdict := range summaryVerdicts {
+		parts = append(parts, fmt.Sprintf("%s %d", verdict.Code(), counts[verdict]))
+	}
+	fmt.Printf("Summary: %s\n", strings.Join(parts, ", "))
+}
+
+/* Runner of tests of the task with settings from flags and task metadata */
+func prepareTaskRunner(contest *model.Contest, taskToken string) (testRunner, error) {
+	task, _ := contest.Tasks[taskToken]
+	limits := determineLimits(&task)
+	taskDir := filepath.Join(contest.RootDir, taskToken)
+	check, err := determineChecker(&task, taskDir)
+	if err != nil {
+		return nil, fmt.Errorf("bad checker: %s", err)
+	}
+	interactorPath, err := determineInteractor(&task, taskDir)
+	if err != nil {
+		return nil, err
+	}
+	return func(w io.Writer, testToken string) (*Outcome, error) {
+		if len(interactorPath) > 0 {
+			return runInteractiveTest(w, taskDir, testToken, limits, interactorPath)
+		}
+		return runSingleTest(w, taskDir, testToken, limits, taskFiles(&task), check)
+	}, nil
+}
+
+/* Name of the group selecting tests of all groups */
+const AllTestGroups = "all"
+
+/* Tokens of tests in the group, the default group consists of samples and own tests */
+func groupTokens(task *model.Task, group string) ([]string, error) {
+	if len(group) == 0 {
+		return task.TestTokens, nil
+	}
+	if group == AllTestGroups {
+		tokens := append([]string(nil), task.TestTokens...)
+		var groups []string
+		for name := range task.TestGroups {
+			groups = append(groups, name)
+		}
+		sort.Strings(groups)
+		for _, name := range groups {
+			tokens = append(tokens, task.TestGroups[name]...)
+		}
+		return tokens, nil
 	}
-	return &Outcome{elapsed, diff}, nil
+	tokens, ok := task.TestGroups[group]
+	if !ok {
+		return nil, fmt.Errorf("task %s has no test group '%s'", task.Token, group)
+	}
+	return tokens, nil
 }
 
 var runCmd = &cobra.Command{
 	Use:   "run [TOKEN1 TOKEN2 ...]",
 	Short: "Run built solution on test cases",
-	Long:  `Run built solution on test cases. Set and order of test cases could be specified in command arguments as test tokens separated by spaces. If no arguments are given, then all available tests are used.`,
+	Long:  `Run built solution on test cases. Set and order of test cases could be specified in command arguments as test tokens separated by spaces. If no arguments are given, then samples and own tests are used, or the tests of the group given with --group, like hidden tests fetched with 'wac fetch --archive'.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		readExecConfig()
-		if len(ExecMethodName) == 0 {
-			ExecMethodName = viper.GetString("DefaultRunMethod")
-		}
-		var ok bool
-		if TheMethod, ok = ExecMethodByName[ExecMethodName]; !ok {
-			log.Fatalf("ERROR exec method '%s' not found in config\n", ExecMethodName)
-		}
-		if len(SolutionName) == 0 {
-			SolutionName = viper.GetString("SolutionName")
+		if err := setupExecMethod(); err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 		contest, err := model.LocateContest()
 		if err != nil {
-			log.Fatalf("ERROR %s\n", err)
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
 		taskToken, err := model.DetermineCurrentTask(contest)
 		if err != nil {
-			log.Fatalf("ERROR can't determine current task: %s\n", err)
+			fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
 		}
 		task, ok := contest.Tasks[taskToken]
 		if !ok {
-			log.Fatalf("ERROR contest have no task for the working directory")
+			fatal(ExitUsageError, "ERROR contest have no task for the working directory")
 		}
 		if UseStdStreams {
 			if len(args) > 0 {
-				log.Fatalf("ERROR test tokens are now allowed when stdin/stdout are used")
+				fatal(ExitUsageError, "ERROR test tokens are now allowed when stdin/stdout are used")
 			}
-			err, _ := doRun("", "")
+			stats, err := doRun("", "", Limits{})
 			if err != nil {
-				log.Fatalf("ERROR failed to run solution: %s", err)
+				fatal(ExitRuntimeFailure, "ERROR failed to run solution: %s", err)
+			}
+			printStderr(os.Stdout, &stats.stderr, 0)
+			if len(stats.failure) > 0 {
+				fatal(ExitRuntimeFailure, "ERROR solution failed: %s", stats.failure)
 			}
 			return
 		}
-		if len(task.TestTokens) == 0 {
-			fmt.Println("No tests.")
-			return
+		tokens, err := groupTokens(&task, TestGroup)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
 		}
+		allTokens, _ := groupTokens(&task, AllTestGroups)
 		for _, testToken := range args {
-			if !util.ContainsString(&task.TestTokens, testToken) {
-				log.Fatalf("ERROR test with token '%s' not found", testToken)
+			if !util.ContainsString(&allTokens, testToken) {
+				fatal(ExitUsageError, "ERROR test with token '%s' not found", testToken)
 			}
 		}
 		var selection *[]string
 		if len(args) > 0 {
 			selection = &args
 		} else {
-			selection = &task.TestTokens
+			selection = &tokens
 		}
-		for _, testToken := range *selection {
-			fmt.Printf("[%s] ... ", testToken)
-			outc, err := runSingleTest(filepath.Join(contest.RootDir, taskToken), testToken)
-			if err != nil {
-				log.Fatalf("ERROR failed to run test '%s': %s", testToken, err)
-			}
-			var msg string
-			if outc.output_differs {
-				msg = "Differs"
-			} else {
-				msg = "Ok"
-			}
-			fmt.Printf("%s -- %dms\n", msg, int(outc.exec_time/1000000))
-			if outc.output_differs && !KeepGoing {
-				break
-			}
+		if len(*selection) == 0 {
+			fmt.Println("No tests.")
+			return
+		}
+		if Jobs > runtime.NumCPU() {
+			log.Printf("WARN %d jobs on %d CPUs: wall time is inflated, rely on cpu time\n", Jobs, runtime.NumCPU())
+		}
+		runTest, err := prepareTaskRunner(contest, taskToken)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		results, err := runTests(os.Stdout, *selection, Jobs, runTest)
+		if err != nil {
+			fatal(ExitRuntimeFailure, "ERROR %s\n", err)
 		}
+		printSummary(countVerdicts(results))
+		os.Exit(exitCodeOf(results))
 	},
 }
 
+/* Flags shared by commands that run the solution on tests */
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&ExecMethodName, "with", "w", "", "Execution method name, like elf (default is set in config under DefaultRunMethod)")
+	cmd.Flags().StringVarP(&SolutionName, "solution", "s", "", "Built solution name, like 'main' (default is set in config under SolutionName)")
+	cmd.Flags().BoolVarP(&UseStdStreams, "interactive", "i", false, "Interactive mode: use stdin and stdout instead of files")
+	cmd.Flags().BoolVarP(&KeepGoing, "keep-going", "k", false, "Keep going when some tests fail")
+	cmd.Flags().StringVarP(&TestGroup, "group", "g", "", "Group of tests to run instead of samples and own tests, '"+AllTestGroups+"' for every test")
+	cmd.Flags().BoolVarP(&BeSilent, "quiet", "q", false, "Do not show differences found in output")
+	addStackFlag(cmd)
+	cmd.Flags().IntVarP(&Jobs, "jobs", "j", 1, "Number of tests to run concurrently")
+	cmd.Flags().DurationVarP(&TimeLimit, "time-limit", "t", 0, "Time limit per test, like 2s (default is the time limit of the task if known, otherwise 5s)")
+	cmd.Flags().Uint64VarP(&MemoryLimit, "memory-limit", "m", 0, "Memory limit per test in megabytes (default is the memory limit of the task if known, otherwise no limit)")
+	cmd.Flags().StringVarP(&CheckerSpec.Mode, "checker", "c", "", "Checker mode: "+strings.Join(checker.Modes, ", ")+" (default is set for the task, otherwise exact)")
+	cmd.Flags().Float64VarP(&CheckerSpec.Epsilon, "epsilon", "", checker.DefaultEpsilon, "Absolute or relative error allowed by float checker")
+	cmd.Flags().StringVarP(&CheckerSpec.Program, "checker-program", "", "", "Checker program for external checker, invoked as 'PROGRAM input expected actual'")
+	cmd.Flags().StringVarP(&InteractorName, "interactor", "", "", "Interactor program for interactive tasks, invoked as 'PROGRAM input output [answer]' (default is '"+DefaultInteractorName+"' in task directory, if the task is interactive)")
+	cmd.Flags().BoolVarP(&WriteTranscript, "transcript", "", false, "Save exchange between solution and interactor into TOKEN.transcript")
+}
+
+func addStackFlag(cmd *cobra.Command) {
+	cmd.Flags().Uint64VarP(&StackSize, "stack", "", 256*1024*1024, "Stack size in bytes")
+}
+
 func init() {
-	runCmd.Flags().StringVarP(&ExecMethodName, "with", "w", "", "Execution method name, like elf (default is set in config under DefaultRunMethod)")
-	runCmd.Flags().StringVarP(&SolutionName, "solution", "s", "", "Built solution name, like 'main' (default is set in config under SolutionName)")
-	runCmd.Flags().BoolVarP(&UseStdStreams, "interactive", "i", false, "Interactive mode: use stdin and stdout instead of files")
-	runCmd.Flags().BoolVarP(&KeepGoing, "keep-going", "k", false, "Keep going when some tests fail")
-	runCmd.Flags().BoolVarP(&BeSilent, "quiet", "q", false, "Do not show differences found in output")
-	runCmd.Flags().Uint64VarP(&StackSize, "stack", "", 256*1024*1024, "Stack size in bytes")
+	addRunFlags(runCmd)
 	RootCmd.AddCommand(runCmd)
 }
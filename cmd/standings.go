@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mxwell/wac/model"
+	"github.com/spf13/cobra"
+)
+
+var StandingsRefresh time.Duration
+var StandingsLeaders int
+
+func formatPoints(points float64) string {
+	return strconv.FormatFloat(points, 'f', -1, 64)
+}
+
+func printStandingsRow(w *tabwriter.Writer, row *model.StandingsRow) {
+	fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t\n", row.Rank, row.Party, formatPoints(row.Points), row.Penalty,
+		strings.Join(row.Solved, " "))
+}
+
+func printStandings(standings *model.Standings) {
+	if standings.Mine != nil {
+		fmt.Printf("Your rank: %d, points: %s\n\n", standings.Mine.Rank, formatPoints(standings.Mine.Points))
+	} else {
+		fmt.Printf("You are not in the standings\n\n")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tPARTY\tPOINTS\tPENALTY\tSOLVED\t")
+	for i := range standings.Leaders {
+		printStandingsRow(w, &standings.Leaders[i])
+	}
+	if standings.Mine != nil && standings.Mine.Rank > len(standings.Leaders) {
+		fmt.Fprintln(w, "...\t\t\t\t\t")
+		printStandingsRow(w, standings.Mine)
+	}
+	w.Flush()
+
+	if standings.Solves == nil {
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tSOLVED BY\t")
+	for _, token := range standings.Tasks {
+		fmt.Fprintf(w, "%s\t%d\t\n", token, standings.Solves[token])
+	}
+	w.Flush()
+}
+
+var standingsCmd = &cobra.Command{
+	Use:   "standings",
+	Short: "Show standings of current contest",
+	Long: `Show own rank, the leaders of the current contest with tasks solved by them, and the number of participants solved every task, if the platform tells it.
+
+The standings are redrawn every --refresh interval until interrupted, zero interval means to show them once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		contest, tracker := locateTracker()
+		refreshPeriodically(StandingsRefresh, func() error {
+			standings, err := tracker.GetStandings(contest, StandingsLeaders)
+			if err != nil {
+				return fmt.Errorf("can't get standings: %s", err)
+			}
+			printStandings(standings)
+			return nil
+		})
+	},
+}
+
+func init() {
+	standingsCmd.Flags().DurationVarP(&StandingsRefresh, "refresh", "r", 30*time.Second, "Interval of refreshing, zero means to show once")
+	standingsCmd.Flags().IntVarP(&StandingsLeaders, "leaders", "n", 10, "Number of leaders to show")
+	RootCmd.AddCommand(standingsCmd)
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mxwell/wac/markdown"
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/platforms"
+	"github.com/mxwell/wac/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+/* name of the statement file in task directory */
+const StatementName = "statement.md"
+
+var StatementRefresh bool
+var StatementNoPager bool
+
+/* Download the image into task directory, the result is its file name */
+func downloadImage(link string, taskDir string, index int) (string, error) {
+	client := &http.Client{Transport: util.PlatformTransport}
+	resp, err := client.Get(link)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	ext := ""
+	if u, err := url.Parse(link); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	if len(ext) == 0 {
+		if exts, err := mime.ExtensionsByType(resp.Header.Get("Content-Type")); err == nil && len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	name := fmt.Sprintf("statement-%d%s", index, ext)
+	f, err := os.Create(filepath.Join(taskDir, name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", err
+	}
+	return name, f.Close()
+}
+
+/* Fetch the statement, convert it to Markdown and save with its images, the result is the path */
+func saveStatement(platform model.Platform, contest *model.Contest, task *model.Task) (string, error) {
+	statementPlatform, ok := platform.(model.StatementPlatform)
+	if !ok {
+		return "", fmt.Errorf("platform %s doesn't give out statements", platform.Name())
+	}
+	statement, err := statementPlatform.GetStatement(task)
+	if err != nil {
+		return "", err
+	}
+	taskDir := filepath.Join(contest.RootDir, task.Token)
+	if err := os.MkdirAll(taskDir, 0777); err != nil {
+		return "", fmt.Errorf("can't create a subdir '%s' for task: %s", taskDir, err)
+	}
+	images := 0
+	text, err := markdown.Convert(statement.Html, statement.Link, func(link string) string {
+		images++
+		name, err := downloadImage(link, taskDir, images)
+		if err != nil {
+			/* the statement is still readable online */
+			log.Printf("[%s] WARN failed to download image %s: %s\n", task.Token, link, err)
+			return link
+		}
+		return name
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to convert statement: %s", err)
+	}
+	header := fmt.Sprintf("# %s\n\n%s\n\n", task.Name, task.Link)
+	statementPath := filepath.Join(taskDir, StatementName)
+	if err := ioutil.WriteFile(statementPath, []byte(header+text), 0644); err != nil {
+		return "", err
+	}
+	return statementPath, nil
+}
+
+/* Save statements of the tasks, failures are only reported */
+func saveStatements(platform model.Platform, contest *model.Contest, tokens []string) {
+	for _, token := range tokens {
+		task := contest.Tasks[token]
+		if statementPath, err := saveStatement(platform, contest, &task); err == nil {
+			log.Printf("[%s] statement saved to %s\n", token, statementPath)
+		} else {
+			log.Printf("[%s] WARN can't save statement: %s\n", token, err)
+		}
+	}
+}
+
+/* Show the file through PAGER or the pager of config, unless the output isn't a terminal */
+func showInPager(filePath string) error {
+	pager := os.Getenv("PAGER")
+	if len(pager) == 0 {
+		pager = viper.GetString("Pager")
+	}
+	args := strings.Fields(pager)
+	if StatementNoPager || len(args) == 0 || !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(os.Stdout, f)
+		return err
+	}
+	cmd := exec.Command(args[0], append(args[1:], filePath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+var statementCmd = &cobra.Command{
+	Use:   "statement [TASK]",
+	Short: "Show statement of task",
+	Long: `Show statement of TASK or of the current task through a pager. The statement is downloaded once and kept as ` + StatementName + ` in the task directory: it's converted to Markdown, formulas are kept as is, images are saved next to it.
+
+Statements of all tasks are saved by 'wac fetch --all' too, if FetchStatements is enabled in config. The pager is taken from PAGER, otherwise from Pager in config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			fatal(ExitUsageError, "ERROR at most one task is expected\n")
+		}
+		contest, err := model.LocateContest()
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		var token string
+		if len(args) == 1 {
+			token = args[0]
+		} else if token, err = model.DetermineCurrentTask(contest); err != nil {
+			fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
+		}
+		task, ok := contest.Tasks[token]
+		if !ok {
+			fatal(ExitUsageError, "ERROR contest has no task %s\n", token)
+		}
+		statementPath := filepath.Join(contest.RootDir, token, StatementName)
+		if StatementRefresh || !util.PathExists(statementPath) {
+			platform := platforms.FindPlatform(task.Link)
+			if platform == nil {
+				fatal(ExitUsageError, "ERROR unable to find platform for task url %s\n", task.Link)
+			}
+			if statementPath, err = saveStatement(platform, contest, &task); err != nil {
+				fatal(ExitPlatformError, "ERROR can't get statement: %s\n", err)
+			}
+		}
+		if err := showInPager(statementPath); err != nil {
+			fatal(ExitUsageError, "ERROR can't show statement: %s\n", err)
+		}
+	},
+}
+
+func init() {
+	statementCmd.Flags().BoolVarP(&StatementRefresh, "refresh", "r", false, "Download the statement again, even if it's saved")
+	statementCmd.Flags().BoolVarP(&StatementNoPager, "no-pager", "", false, "Print the statement without pager")
+	RootCmd.AddCommand(statementCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/platforms"
+	"github.com/spf13/cobra"
+)
+
+var StatusRefresh time.Duration
+
+/* Platform of the current contest, which is able to show its progress */
+func locateTracker() (*model.Contest, model.ContestTracker) {
+	contest, err := model.LocateContest()
+	if err != nil {
+		fatal(ExitUsageError, "ERROR %s\n", err)
+	}
+	platform := platforms.FindPlatform(contest.Link)
+	if platform == nil {
+		fatal(ExitUsageError, "ERROR unable to find platform for contest url %s\n", contest.Link)
+	}
+	tracker, ok := platform.(model.ContestTracker)
+	if !ok {
+		fatal(ExitUsageError, "ERROR platform %s doesn't show submissions and standings\n", platform.Name())
+	}
+	return contest, tracker
+}
+
+/* Show once, or redraw the screen with the given interval until interrupted */
+func refreshPeriodically(interval time.Duration, show func() error) {
+	if interval == 0 {
+		if err := show(); err != nil {
+			fatal(ExitPlatformError, "ERROR %s\n", err)
+		}
+		return
+	}
+	for {
+		clearScreen()
+		fmt.Printf("%s, refreshed every %s, press Ctrl+C to stop\n\n", time.Now().Format("15:04:05"), interval)
+		if err := show(); err != nil {
+			/* the platform could be temporarily unavailable during the contest */
+			fmt.Printf("ERROR %s\n", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func printSubmissions(submissions []model.Submission) {
+	sort.SliceStable(submissions, func(i, j int) bool {
+		return submissions[i].SubmittedAt.After(submissions[j].SubmittedAt)
+	})
+	if len(submissions) == 0 {
+		fmt.Println("No submissions yet")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSUBMITTED\tTASK\tLANGUAGE\tVERDICT\tTIME\tMEMORY\t")
+	for _, submission := range submissions {
+		submitted, elapsed, memory := "", "", ""
+		if !submission.SubmittedAt.IsZero() {
+			submitted = submission.SubmittedAt.Local().Format("01-02 15:04:05")
+		}
+		if submission.Time > 0 {
+			elapsed = submission.Time.String()
+		}
+		if submission.Memory > 0 {
+			memory = formatMemory(submission.Memory)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", submission.Id, submitted, submission.Task,
+			submission.Language, submission.Verdict, elapsed, memory)
+	}
+	w.Flush()
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List own submissions to current contest",
+	Long: `List own submissions to the current contest with their verdicts, time and memory, the newest first.
+
+The list is redrawn every --refresh interval until interrupted, zero interval means to show it once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		contest, tracker := locateTracker()
+		refreshPeriodically(StatusRefresh, func() error {
+			submissions, err := tracker.GetSubmissions(contest)
+			if err != nil {
+				return fmt.Errorf("can't get submissions: %s", err)
+			}
+			printSubmissions(submissions)
+			return nil
+		})
+	},
+}
+
+func init() {
+	statusCmd.Flags().DurationVarP(&StatusRefresh, "refresh", "r", 10*time.Second, "Interval of refreshing, zero means to show once")
+	RootCmd.AddCommand(statusCmd)
+}
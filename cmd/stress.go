@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mxwell/wac/checker"
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+	"github.com/spf13/cobra"
+)
+
+/* number of seeds tried for every smaller size during shrink */
+const ShrinkAttempts = 20
+
+var StressBuildMethod string
+var StressGeneratorBuildMethod string
+var StressGeneratorExecMethodName string
+var StressIterations int
+var StressTimeBudget time.Duration
+var StressSeed int64
+var StressSize int
+var StressShrink bool
+var BruteTimeLimit time.Duration
+
+/* Program name is the source name without extension */
+func programName(source string) string {
+	return strings.TrimSuffix(source, filepath.Ext(source))
+}
+
+/* Build program from resolved source, compiler messages are printed in case of failure */
+func buildFromSource(method *BuildMethod, input string, output string) error {
+	var out BuildOutput
+	if err := buildProgram(method, input, output, &out); err != nil {
+		printBuildOutput(&out)
+		return fmt.Errorf("failed to build %s: %s", input, err)
+	}
+	return nil
+}
+
+type stressSession struct {
+	generator     string
+	generatorExec *ExecMethod
+	brute         string
+	limits        Limits
+	files         FileIO
+	check         checker.Checker
+	inputPath     string
+	answerPath    string
+	resultPath    string
+}
+
+type stressFailure struct {
+	verdict     Verdict
+	description string
+}
+
+func (f *stressFailure) String() string {
+	if len(f.description) > 0 {
+		return f.verdict.String() + " (" + f.description + ")"
+	}
+	return f.verdict.String()
+}
+
+/* Failure of the run or nil if the program has finished normally */
+func runFailure(stats *RunStats) *stressFailure {
+	if stats.timed_out {
+		return &stressFailure{VerdictTimeLimit, ""}
+	}
+	if stats.memory_exceeded {
+		return &stressFailure{VerdictMemoryLimit, ""}
+	}
+	if len(stats.failure) > 0 {
+		return &stressFailure{VerdictRuntimeError, stats.failure}
+	}
+	return nil
+}
+
+/*
+ * Generate a test with the seed and size, then run brute force and solution on it.
+ * Returns failure of the solution or nil if the solution is right.
+ */
+func (s *stressSession) attempt(seed int64, size int) (*stressFailure, error) {
+	generator := getProgramCommand(s.generatorExec, s.generator)
+	generator.Args = append(generator.Args, strconv.FormatInt(seed, 10), strconv.Itoa(size))
+	stats, err := runProgram(generator, os.DevNull, s.inputPath, Limits{time: BruteTimeLimit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run generator: %s", err)
+	}
+	if failure := runFailure(stats); failure != nil {
+		printStderr(os.Stdout, &stats.stderr, StderrExcerptLines)
+		return nil, fmt.Errorf("generator failed on seed %d: %s", seed, failure)
+	}
+
+	/* brute force reads and writes the same files as the solution */
+	stats, err = doRunProgramTask(getProgramCommand(TheMethod, s.brute), s.inputPath, s.answerPath, Limits{time: BruteTimeLimit}, s.files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run brute force: %s", err)
+	}
+	if failure := runFailure(stats); failure != nil {
+		printStderr(os.Stdout, &stats.stderr, StderrExcerptLines)
+		return nil, fmt.Errorf("brute force failed on seed %d: %s", seed, failure)
+	}
+
+	stats, err = doRunTask(s.inputPath, s.resultPath, s.limits, s.files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run solution: %s", err)
+	}
+	if failure := runFailure(stats); failure != nil {
+		return failure, nil
+	}
+	result, err := s.check.Check(s.inputPath, s.answerPath, s.resultPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check output: %s", err)
+	}
+	if !result.Ok {
+		return &stressFailure{VerdictDiffers, result.Message}, nil
+	}
+	return nil, nil
+}
+
+/* Look for a failing test of smaller size. The files of the last failing attempt are kept. */
+func (s *stressSession) shrink(seed int64, size int) (int64, int, *stressFailure, error) {
+	for smaller := 1; smaller < size; smaller++ {
+		for i := int64(0); i < ShrinkAttempts; i++ {
+			fmt.Printf("\rShrinking: size %d, seed %d ...", smaller, seed+i)
+			failure, err := s.attempt(seed+i, smaller)
+			if err != nil {
+				return 0, 0, nil, err
+			}
+			if failure != nil {
+				fmt.Println()
+				return seed + i, smaller, failure, nil
+			}
+		}
+	}
+	fmt.Println()
+	/* restore files of the original failure */
+	failure, err := s.attempt(seed, size)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if failure == nil {
+		return 0, 0, nil, fmt.Errorf("failure on seed %d is not reproducible", seed)
+	}
+	return seed, size, failure, nil
+}
+
+/* Register generated test under the first free stressN token, like addtest does */
+func saveStressTest(contest *model.Contest, taskToken string, inputPath string, answerPath string) (string, error) {
+	task, _ := contest.Tasks[taskToken]
+	taskDir := filepath.Join(contest.RootDir, taskToken)
+	var testToken string
+	for n := 1; ; n++ {
+		testToken = fmt.Sprintf("stress%d", n)
+		if !util.ContainsString(&task.TestTokens, testToken) && !util.PathExists(filepath.Join(taskDir, testToken+".in")) {
+			break
+		}
+	}
+	if err := os.Rename(inputPath, filepath.Join(taskDir, testToken+".in")); err != nil {
+		return "", err
+	}
+	if err := os.Rename(answerPath, filepath.Join(taskDir, testToken+".out")); err != nil {
+		return "", err
+	}
+	task.TestTokens = append(task.TestTokens, testToken)
+	contest.Tasks[taskToken] = task
+	if err := model.SaveContest(contest); err != nil {
+		return "", fmt.Errorf("failed to save contest metadata: %s", err)
+	}
+	return testToken, nil
+}
+
+var stressCmd = &cobra.Command{
+	Use:   "stress GENERATOR BRUTE",
+	Short: "Compare solution with brute force on generated tests",
+	Long: `Build solution, generator and brute force from sources GENERATOR and BRUTE (extension could be given as ".*"), then repeatedly generate tests with increasing seeds and compare output of the solution with output of the brute force using the task checker.
+
+Generator is invoked as 'GENERATOR SEED SIZE' and should print a test to stdout. Generator written in another language is built with --gen-build and run with --gen-with, like --gen-with python3. The first failing test is shrunk, if possible, and added to the task as stressN.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			fatal(ExitUsageError, "ERROR generator and brute force sources are required")
+		}
+		readConfig()
+		if err := setupExecMethod(); err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		contest, err := model.LocateContest()
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		taskToken, err := model.DetermineCurrentTask(contest)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
+		}
+		task, _ := contest.Tasks[taskToken]
+		if task.Interactive {
+			fatal(ExitUsageError, "ERROR stress testing of interactive tasks is not supported")
+		}
+		taskDir := filepath.Join(contest.RootDir, taskToken)
+		check, err := determineChecker(&task, taskDir)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR bad checker: %s", err)
+		}
+
+		method, err := findBuildMethod(StressBuildMethod)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		generatorMethod := method
+		if len(StressGeneratorBuildMethod) > 0 {
+			generatorMethod, err = findBuildMethod(StressGeneratorBuildMethod)
+			if err != nil {
+				fatal(ExitUsageError, "ERROR %s\n", err)
+			}
+		}
+		generatorExec := TheMethod
+		if len(StressGeneratorExecMethodName) > 0 {
+			var ok bool
+			if generatorExec, ok = ExecMethodByName[StressGeneratorExecMethodName]; !ok {
+				fatal(ExitUsageError, "ERROR exec method '%s' not found in config\n", StressGeneratorExecMethodName)
+			}
+		}
+		solutionSource, err := getInput(method)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR bad input: %s\n", err)
+		}
+		generatorSource, err := resolveSource(args[0], generatorMethod)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR bad generator: %s\n", err)
+		}
+		bruteSource, err := resolveSource(args[1], method)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR bad brute force: %s\n", err)
+		}
+		session := &stressSession{
+			generator:     programName(args[0]),
+			generatorExec: generatorExec,
+			brute:         programName(args[1]),
+			limits:        determineLimits(&task),
+			files:         taskFiles(&task),
+			check:         check,
+			inputPath:     filepath.Join(taskDir, ".stress.in"),
+			answerPath:    filepath.Join(taskDir, ".stress.out"),
+			resultPath:    filepath.Join(taskDir, ".stress.result"),
+		}
+		if err := buildFromSource(method, solutionSource, SolutionName); err != nil {
+			fatal(ExitCompileError, "ERROR %s\n", err)
+		}
+		if err := buildFromSource(generatorMethod, generatorSource, session.generator); err != nil {
+			fatal(ExitCompileError, "ERROR %s\n", err)
+		}
+		if err := buildFromSource(method, bruteSource, session.brute); err != nil {
+			fatal(ExitCompileError, "ERROR %s\n", err)
+		}
+		defer os.Remove(session.inputPath)
+		defer os.Remove(session.answerPath)
+		defer os.Remove(session.resultPath)
+
+		start := time.Now()
+		seed := StressSeed
+		var failure *stressFailure
+		iteration := 0
+		for ; StressIterations == 0 || iteration < StressIterations; iteration++ {
+			if StressTimeBudget > 0 && time.Since(start) > StressTimeBudget {
+				fmt.Printf("\nTime budget of %s is over", StressTimeBudget)
+				break
+			}
+			fmt.Printf("\r[%d] seed %d ...", iteration+1, seed)
+			failure, err = session.attempt(seed, StressSize)
+			if err != nil {
+				fmt.Println()
+				fatal(ExitRuntimeFailure, "ERROR %s\n", err)
+			}
+			if failure != nil {
+				break
+			}
+			seed++
+		}
+		fmt.Println()
+		if failure == nil {
+			fmt.Printf("No failures in %d iteration(s)\n", iteration)
+			return
+		}
+		fmt.Printf("Failure on seed %d, size %d: %s\n", seed, StressSize, failure)
+		size := StressSize
+		if StressShrink {
+			seed, size, failure, err = session.shrink(seed, size)
+			if err != nil {
+				fatal(ExitRuntimeFailure, "ERROR %s\n", err)
+			}
+			fmt.Printf("Smallest failure found on seed %d, size %d: %s\n", seed, size, failure)
+		}
+		testToken, err := saveStressTest(contest, taskToken, session.inputPath, session.answerPath)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR failed to save test: %s", err)
+		}
+		fmt.Printf("Test %s is added to task %s\n", testToken, taskToken)
+		os.Exit(failure.verdict.ExitCode())
+	},
+}
+
+func init() {
+	stressCmd.Flags().StringVarP(&StressBuildMethod, "build", "b", "", "Build method for solution and brute force (default is set in config under DefaultBuildMethod)")
+	addInputFlag(stressCmd, "i")
+	stressCmd.Flags().StringVarP(&StressGeneratorBuildMethod, "gen-build", "", "", "Build method for generator (default is the one for solution)")
+	stressCmd.Flags().StringVarP(&StressGeneratorExecMethodName, "gen-with", "", "", "Execution method for generator, like python3 (default is the one for solution)")
+	stressCmd.Flags().StringVarP(&ExecMethodName, "with", "w", "", "Execution method name, like elf (default is set in config under DefaultRunMethod)")
+	stressCmd.Flags().IntVarP(&StressIterations, "iterations", "n", 1000, "Maximum number of iterations, 0 for no limit")
+	stressCmd.Flags().DurationVarP(&StressTimeBudget, "budget", "", time.Minute, "Time budget for the whole session, 0 for no limit")
+	stressCmd.Flags().Int64VarP(&StressSeed, "seed", "", 1, "Seed of the first iteration")
+	stressCmd.Flags().IntVarP(&StressSize, "size", "", 10, "Size parameter passed to generator")
+	stressCmd.Flags().BoolVarP(&StressShrink, "shrink", "", true, "Look for a failing test of smaller size")
+	stressCmd.Flags().DurationVarP(&TimeLimit, "time-limit", "t", 0, "Time limit for solution, like 2s (default is the time limit of the task if known, otherwise 5s)")
+	stressCmd.Flags().Uint64VarP(&MemoryLimit, "memory-limit", "m", 0, "Memory limit for solution in megabytes (default is the memory limit of the task if known, otherwise no limit)")
+	stressCmd.Flags().DurationVarP(&BruteTimeLimit, "brute-time-limit", "", 10*time.Second, "Time limit for generator and brute force")
+	addStackFlag(stressCmd)
+	RootCmd.AddCommand(stressCmd)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/platforms"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var SubmitBuildMethod string
+var SubmitLanguageId string
+var SubmitWait bool
+var SubmitPollInterval time.Duration
+
+func describeSubmission(status *model.SubmissionStatus) string {
+	if !status.Final && status.Tests > 0 {
+		return fmt.Sprintf("%s, test %d", status.Verdict, status.Tests)
+	}
+	return status.Verdict
+}
+
+/* Poll the platform until the verdict is final, progress is shown on one line */
+func waitForVerdict(submitter model.Submitter, task *model.Task, id string) (*model.SubmissionStatus, error) {
+	last := ""
+	for {
+		status, err := submitter.GetSubmission(task, id)
+		if err != nil {
+			return nil, err
+		}
+		line := describeSubmission(status)
+		if line != last {
+			fmt.Printf("\r%-40s", line)
+			last = line
+		}
+		if status.Final {
+			fmt.Println()
+			return status, nil
+		}
+		time.Sleep(SubmitPollInterval)
+	}
+}
+
+var submitCmd = &cobra.Command{
+	Use:   "submit [FILE]",
+	Short: "Submit solution to platform",
+	Long: `Submit FILE or the solution of current task to the platform, then wait for the final verdict. Language is determined by the build method, unless platform-specific ID is given with --language-id.
+
+Platform hosts could be overridden with WAC_<PLATFORM>_HOST, like WAC_CODEFORCES_HOST=http://127.0.0.1:8080, to test against a local fake judge.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			fatal(ExitUsageError, "ERROR at most one file is expected")
+		}
+		readConfig()
+		method, err := findBuildMethod(SubmitBuildMethod)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		source := viper.GetString("SolutionName") + ".*"
+		if len(args) == 1 {
+			source = args[0]
+		}
+		source, err = resolveSource(source, method)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR bad input: %s\n", err)
+		}
+		contest, err := model.LocateContest()
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		taskToken, err := model.DetermineCurrentTask(contest)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
+		}
+		task, _ := contest.Tasks[taskToken]
+		platform := platforms.FindPlatform(task.Link)
+		if platform == nil {
+			fatal(ExitUsageError, "ERROR unable to find platform for task url %s\n", task.Link)
+		}
+		submitter, ok := platform.(model.Submitter)
+		if !ok {
+			fatal(ExitUsageError, "ERROR platform %s doesn't support submission\n", platform.Name())
+		}
+		languageId := SubmitLanguageId
+		if len(languageId) == 0 {
+			if languageId, ok = submitter.LanguageId(method.language.name); !ok {
+				fatal(ExitUsageError, "ERROR language %s is unknown to %s, use --language-id\n", method.language.name, platform.Name())
+			}
+		}
+
+		id, err := submitter.Submit(&task, languageId, source)
+		if err != nil {
+			fatal(ExitPlatformError, "ERROR can't submit %s: %s\n", source, err)
+		}
+		fmt.Printf("%s is submitted to task %s, submission %s\n", source, taskToken, id)
+		if !SubmitWait {
+			return
+		}
+		status, err := waitForVerdict(submitter, &task, id)
+		if err != nil {
+			fatal(ExitPlatformError, "ERROR can't get status of submission %s: %s\n", id, err)
+		}
+		switch {
+		case status.Accepted:
+			os.Exit(ExitOk)
+		case status.CompileError:
+			os.Exit(ExitCompileError)
+		}
+		os.Exit(ExitWrongAnswer)
+	},
+}
+
+func init() {
+	submitCmd.Flags().StringVarP(&SubmitBuildMethod, "build", "b", "", "Build method determining language (default is set in config under DefaultBuildMethod)")
+	submitCmd.Flags().StringVarP(&SubmitLanguageId, "language-id", "l", "", "Platform-specific ID of language, overrides the one of build method")
+	submitCmd.Flags().BoolVarP(&SubmitWait, "wait", "", true, "Wait for the final verdict")
+	submitCmd.Flags().DurationVarP(&SubmitPollInterval, "poll-interval", "", 2*time.Second, "Interval between checks of submission status")
+	RootCmd.AddCommand(submitCmd)
+}
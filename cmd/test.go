@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var TestBuildMethod string
+var ForceBuild bool
+
+var testCmd = &cobra.Command{
+	Use:   "test [TOKEN1 TOKEN2 ...]",
+	Short: "Build solution and run it on test cases",
+	Long: `Build solution and run it on test cases, like build followed by run. The build is skipped if neither the source nor the build method have changed since the last successful build. If the build fails, tests are not run.
+
+Test cases are selected in the same way as in run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		readConfig()
+		method, err := findBuildMethod(TestBuildMethod)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		if len(SolutionName) == 0 {
+			SolutionName = viper.GetString("SolutionName")
+		}
+		input, err := getInput(method)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR bad input: %s\n", err)
+		}
+		if !ForceBuild && isUpToDate(method, input, SolutionName) {
+			fmt.Println("Build is up to date")
+		} else {
+			var out BuildOutput
+			err = buildProgram(method, input, SolutionName, &out)
+			printBuildOutput(&out)
+			if err != nil {
+				fatal(ExitCompileError, "ERROR Build failed: %s\n", err)
+			}
+			fmt.Println("Build OK")
+		}
+		runCmd.Run(cmd, args)
+	},
+}
+
+func init() {
+	testCmd.Flags().StringVarP(&TestBuildMethod, "build", "b", "", "Build method (default is set in config under DefaultBuildMethod)")
+	testCmd.Flags().BoolVarP(&ForceBuild, "force", "f", false, "Build even if the build is up to date")
+	addRunFlags(testCmd)
+	/* -i is taken by run flags */
+	addInputFlag(testCmd, "")
+	RootCmd.AddCommand(testCmd)
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mxwell/wac/model"
+	"github.com/spf13/cobra"
+)
+
+/* how often watched files are checked for changes */
+const WatchInterval = 300 * time.Millisecond
+
+var WatchBuildMethod string
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+/* State of files, missing files are recorded with zero state */
+func takeSnapshot(paths []string) map[string]fileState {
+	snapshot := make(map[string]fileState)
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			snapshot[path] = fileState{info.ModTime(), info.Size()}
+		} else {
+			snapshot[path] = fileState{}
+		}
+	}
+	return snapshot
+}
+
+func sameSnapshots(a map[string]fileState, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || other != state {
+			return false
+		}
+	}
+	return true
+}
+
+/* Tests of the task selected by --group, like in run */
+func watchedTokens(contest *model.Contest, taskToken string) []string {
+	task := contest.Tasks[taskToken]
+	tokens, _ := groupTokens(&task, TestGroup)
+	return tokens
+}
+
+/* Solution source, contest metadata and files of the watched tests */
+func watchedPaths(contest *model.Contest, taskToken string, source string) []string {
+	paths := []string{source, model.GetRootFile(contest)}
+	taskDir := filepath.Join(contest.RootDir, taskToken)
+	for _, testToken := range watchedTokens(contest, taskToken) {
+		prefix := filepath.Join(taskDir, testToken)
+		paths = append(paths, prefix+".in", prefix+".out")
+	}
+	return paths
+}
+
+func clearScreen() {
+	fmt.Print("\033[H\033[2J")
+}
+
+func printVerdictTable(results []TestResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tVERDICT\tTIME\tCPU\tMEMORY\t")
+	for _, result := range results {
+		outc := result.outcome
+		verdict := outc.verdict.String()
+		if len(outc.details) > 0 {
+			verdict += " (" + outc.details + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%dms\t%dms\t%s\t\n", result.token, verdict, int(outc.exec_time/time.Millisecond), int(outc.cpu_time/time.Millisecond), formatMemory(outc.peak_memory))
+	}
+	w.Flush()
+}
+
+/* Build the solution and run all tests, then show results in place of the previous ones */
+func watchCycle(contest *model.Contest, taskToken string, method *BuildMethod, source string) {
+	var out BuildOutput
+	buildErr := buildProgram(method, source, SolutionName, &out)
+	clearScreen()
+	fmt.Printf("[%s] %s -- %s\n\n", taskToken, source, time.Now().Format("15:04:05"))
+	if buildErr != nil {
+		fmt.Printf("Build failed: %s\n", buildErr)
+		printBuildOutput(&out)
+		return
+	}
+	tokens := watchedTokens(contest, taskToken)
+	if len(tokens) == 0 {
+		fmt.Println("No tests.")
+		return
+	}
+	runTest, err := prepareTaskRunner(contest, taskToken)
+	if err != nil {
+		fmt.Printf("ERROR %s\n", err)
+		return
+	}
+	results, err := runTests(ioutil.Discard, tokens, Jobs, runTest)
+	printVerdictTable(results)
+	if err != nil {
+		fmt.Printf("\nERROR %s\n", err)
+		return
+	}
+	fmt.Println()
+	printSummary(countVerdicts(results))
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Rebuild and rerun tests on every change",
+	Long:  `Watch solution source and test files of current task. On every change the solution is built and, if the build succeeds, tests are run. Tests are selected by --group in the same way as in run. Results are shown as a table, compile errors are shown instead of the table.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		readConfig()
+		if err := setupExecMethod(); err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		method, err := findBuildMethod(WatchBuildMethod)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		source, err := getInput(method)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR bad input: %s\n", err)
+		}
+		contest, err := model.LocateContest()
+		if err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		taskToken, err := model.DetermineCurrentTask(contest)
+		if err != nil {
+			fatal(ExitUsageError, "ERROR can't determine current task: %s\n", err)
+		}
+		if UseStdStreams {
+			fatal(ExitUsageError, "ERROR stdin and stdout can't be used while watching")
+		}
+		task := contest.Tasks[taskToken]
+		if _, err := groupTokens(&task, TestGroup); err != nil {
+			fatal(ExitUsageError, "ERROR %s\n", err)
+		}
+		/* all tests are shown in the table */
+		KeepGoing = true
+		BeSilent = true
+
+		var last map[string]fileState
+		for {
+			/* reload metadata to notice tests added meanwhile */
+			if reloaded, err := model.LoadContest(model.GetRootFile(contest)); err == nil {
+				contest = reloaded
+			}
+			snapshot := takeSnapshot(watchedPaths(contest, taskToken, source))
+			if !sameSnapshots(snapshot, last) {
+				last = snapshot
+				watchCycle(contest, taskToken, method, source)
+			}
+			time.Sleep(WatchInterval)
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().StringVarP(&WatchBuildMethod, "build", "b", "", "Build method (default is set in config under DefaultBuildMethod)")
+	addRunFlags(watchCmd)
+	/* -i is taken by run flags */
+	addInputFlag(watchCmd, "")
+	RootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,284 @@
+package credentials
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+/*
+ * Credentials are looked up in the following sources, in order:
+ *   1. environment variables WAC_<PLATFORM>_<KEY>, like WAC_ATCODER_USERNAME;
+ *   2. the encrypted file in config directory, its passphrase is taken from
+ *      WAC_PASSPHRASE or asked interactively;
+ *   3. interactive prompt.
+ */
+const PassphraseVariable = "WAC_PASSPHRASE"
+
+const saltSize = 16
+
+/* Credentials of one platform by key */
+type Values map[string]string
+
+/* Decrypted content of the file: credentials by platform name */
+type store map[string]Values
+
+var mutex sync.Mutex
+var stdin = bufio.NewReader(os.Stdin)
+var passphrase []byte
+var decrypted store
+
+func StorePath() string {
+	return filepath.Join(util.GetDefaultLocation(), "credentials.enc")
+}
+
+func variableName(platform string, key string) string {
+	return strings.ToUpper("WAC_" + platform + "_" + key)
+}
+
+func readLine(prompt string, secret bool) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if secret && terminal.IsTerminal(int(syscall.Stdin)) {
+		buffer, err := terminal.ReadPassword(int(syscall.Stdin))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read input: %s", err)
+		}
+		return strings.TrimSpace(string(buffer)), nil
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return "", fmt.Errorf("failed to read input: %s", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func getPassphrase() ([]byte, error) {
+	if passphrase != nil {
+		return passphrase, nil
+	}
+	if value, ok := os.LookupEnv(PassphraseVariable); ok {
+		passphrase = []byte(value)
+		return passphrase, nil
+	}
+	/* a new file is encrypted with what is typed, so a typo would lock the user out */
+	create := !util.PathExists(StorePath())
+	prompt := "Enter passphrase of credentials file: "
+	if create {
+		prompt = "Enter new passphrase of credentials file: "
+	}
+	value, err := readLine(prompt, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	if create {
+		repeated, err := readLine("Repeat passphrase: ", true)
+		if err != nil {
+			return nil, err
+		}
+		if repeated != value {
+			return nil, fmt.Errorf("passphrases don't match")
+		}
+	}
+	passphrase = []byte(value)
+	return passphrase, nil
+}
+
+func makeCipher(salt []byte) (cipher.AEAD, error) {
+	phrase, err := getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(phrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+/* File consists of salt, nonce and encrypted JSON. Missing file is an empty store. */
+func loadStore() (store, error) {
+	if decrypted != nil {
+		return decrypted, nil
+	}
+	path := StorePath()
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		decrypted = make(store)
+		return decrypted, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < saltSize {
+		return nil, fmt.Errorf("file %s is corrupted", path)
+	}
+	aead, err := makeCipher(b[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	b = b[saltSize:]
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("file %s is corrupted", path)
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		/* the passphrase shouldn't be reused for saving */
+		passphrase = nil
+		return nil, fmt.Errorf("wrong passphrase or corrupted file %s", path)
+	}
+	var result store
+	if err := json.Unmarshal(plain, &result); err != nil {
+		return nil, fmt.Errorf("file %s is corrupted: %s", path, err)
+	}
+	decrypted = result
+	return decrypted, nil
+}
+
+func saveStore(s store) error {
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	aead, err := makeCipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data := append(salt, nonce...)
+	data = aead.Seal(data, nonce, plain, nil)
+	path := StorePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func missing(values Values, fields []model.CredentialField) bool {
+	for _, field := range fields {
+		if _, ok := values[field.Key]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Get credentials of the platform. Sources are tried in order until all
+ * fields are known, required fields absent in all sources are prompted for.
+ */
+func Get(platform string, fields []model.CredentialField) (Values, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	values := make(Values)
+	for _, field := range fields {
+		if value, ok := os.LookupEnv(variableName(platform, field.Key)); ok {
+			values[field.Key] = value
+		}
+	}
+	if missing(values, fields) && util.PathExists(StorePath()) {
+		s, err := loadStore()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %s", err)
+		}
+		for _, field := range fields {
+			if _, ok := values[field.Key]; ok {
+				continue
+			}
+			if value, ok := s[platform][field.Key]; ok {
+				values[field.Key] = value
+			}
+		}
+	}
+	for _, field := range fields {
+		if _, ok := values[field.Key]; ok || field.Optional {
+			continue
+		}
+		value, err := readLine(field.Prompt+": ", field.Secret)
+		if err != nil {
+			return nil, err
+		}
+		values[field.Key] = value
+	}
+	return values, nil
+}
+
+/* Ask for all fields, including optional ones, ignoring environment and saved values */
+func Prompt(fields []model.CredentialField) (Values, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	values := make(Values)
+	for _, field := range fields {
+		prompt := field.Prompt
+		if field.Optional {
+			prompt += " (optional)"
+		}
+		value, err := readLine(prompt+": ", field.Secret)
+		if err != nil {
+			return nil, err
+		}
+		if len(value) > 0 || !field.Optional {
+			values[field.Key] = value
+		}
+	}
+	return values, nil
+}
+
+/* Save credentials of the platform in the encrypted file, replacing old ones */
+func Save(platform string, values Values) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	s, err := loadStore()
+	if err != nil {
+		return err
+	}
+	s[platform] = values
+	return saveStore(s)
+}
+
+/* Remove credentials of the platform from the encrypted file */
+func Forget(platform string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !util.PathExists(StorePath()) {
+		return nil
+	}
+	s, err := loadStore()
+	if err != nil {
+		return err
+	}
+	if _, ok := s[platform]; !ok {
+		return nil
+	}
+	delete(s, platform)
+	return saveStore(s)
+}
@@ -0,0 +1,152 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mxwell/wac/model"
+)
+
+var fields = []model.CredentialField{
+	{Key: "username", Prompt: "User name"},
+	{Key: "password", Prompt: "Password", Secret: true},
+	{Key: "key", Prompt: "API key", Optional: true},
+}
+
+/* Fresh config directory and no state left from previous reads, as in a new run */
+func setup(t *testing.T, phrase string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(PassphraseVariable, phrase)
+	reset()
+	/* nothing is typed, so prompts fail */
+	stdin = bufio.NewReader(strings.NewReader(""))
+}
+
+/* Forget what was read, so the next call reads the file again */
+func reset() {
+	passphrase = nil
+	decrypted = nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	setup(t, "secret phrase")
+	saved := Values{"username": "tourist", "password": "p@ss w0rd", "key": "abc"}
+	if err := Save("atcoder", saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save("codeforces", Values{"handle": "petr"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(StorePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("tourist")) || bytes.Contains(b, []byte("p@ss")) {
+		t.Errorf("credentials are stored in plain text")
+	}
+
+	reset()
+	values, err := Get("atcoder", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range saved {
+		if values[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, values[key])
+		}
+	}
+	reset()
+	values, err = Get("codeforces", []model.CredentialField{{Key: "handle", Prompt: "Handle"}})
+	if err != nil || values["handle"] != "petr" {
+		t.Errorf("credentials of another platform are lost: %v, %v", values, err)
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	setup(t, "right")
+	if err := Save("atcoder", Values{"username": "tourist", "password": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PassphraseVariable, "wrong")
+	reset()
+	if _, err := Get("atcoder", fields); err == nil {
+		t.Errorf("file is decrypted with wrong passphrase")
+	}
+}
+
+func TestEnvironmentFirst(t *testing.T) {
+	setup(t, "phrase")
+	if err := Save("atcoder", Values{"username": "saved", "password": "saved"}); err != nil {
+		t.Fatal(err)
+	}
+	reset()
+	t.Setenv("WAC_ATCODER_USERNAME", "env")
+	values, err := Get("atcoder", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["username"] != "env" || values["password"] != "saved" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if _, ok := values["key"]; ok {
+		t.Errorf("optional field absent everywhere is set: %v", values)
+	}
+}
+
+func TestMissingRequired(t *testing.T) {
+	setup(t, "phrase")
+	if _, err := Get("atcoder", fields); err == nil {
+		t.Errorf("required field absent everywhere isn't prompted for")
+	}
+	stdin = bufio.NewReader(strings.NewReader("typed\ntyped too\n"))
+	values, err := Get("atcoder", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["username"] != "typed" || values["password"] != "typed too" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestForget(t *testing.T) {
+	setup(t, "phrase")
+	if err := Save("atcoder", Values{"username": "tourist", "password": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Forget("atcoder"); err != nil {
+		t.Fatal(err)
+	}
+	reset()
+	t.Setenv("WAC_ATCODER_PASSWORD", "x")
+	if _, err := Get("atcoder", fields); err == nil {
+		t.Errorf("forgotten credentials are still found")
+	}
+}
+
+func TestNewPassphraseConfirmed(t *testing.T) {
+	setup(t, "")
+	os.Unsetenv(PassphraseVariable)
+	stdin = bufio.NewReader(strings.NewReader("first\nsecond\n"))
+	if err := Save("atcoder", Values{"username": "tourist"}); err == nil {
+		t.Errorf("store is created with mistyped passphrase")
+	}
+	if _, err := os.Stat(StorePath()); !os.IsNotExist(err) {
+		t.Errorf("store file is written: %v", err)
+	}
+	stdin = bufio.NewReader(strings.NewReader("phrase\nphrase\n"))
+	if err := Save("atcoder", Values{"username": "tourist"}); err != nil {
+		t.Fatal(err)
+	}
+	/* existing store is opened with a single prompt */
+	reset()
+	stdin = bufio.NewReader(strings.NewReader("phrase\n"))
+	values, err := Get("atcoder", []model.CredentialField{{Key: "username", Prompt: "User name"}})
+	if err != nil || values["username"] != "tourist" {
+		t.Errorf("unexpected values %v, %v", values, err)
+	}
+}
@@ -0,0 +1,293 @@
+package markdown
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+/*
+ * Converter of statements from HTML to Markdown. Text is written as is, so
+ * formulas like $a_i$ stay intact, variables of <var> become formulas.
+ */
+type converter struct {
+	out   []byte
+	base  *url.URL
+	image func(link string) string
+	lists []list
+}
+
+type list struct {
+	ordered bool
+	count   int
+}
+
+/* Elements which aren't a part of the statement text */
+var skippedElements = map[string]bool{"script": true, "style": true, "head": true, "button": true, "noscript": true}
+
+/*
+ * Convert HTML fragment to Markdown. Relative links are resolved against base,
+ * image gives the link to use for the image by its absolute URL, like a local copy.
+ */
+func Convert(fragment string, base string, image func(link string) string) (string, error) {
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return "", err
+	}
+	c := &converter{base: baseUrl, image: image}
+	for _, node := range nodes {
+		c.render(node)
+	}
+	return strings.TrimSpace(string(c.out)) + "\n", nil
+}
+
+func (c *converter) write(s string) {
+	c.out = append(c.out, s...)
+}
+
+func (c *converter) atLineStart() bool {
+	return len(c.out) == 0 || c.out[len(c.out)-1] == '\n'
+}
+
+/* End the current line, so that n line breaks are in a row, unless it's the start */
+func (c *converter) newlines(n int) {
+	c.out = bytes.TrimRight(c.out, " \t")
+	if len(c.out) == 0 {
+		return
+	}
+	for i := len(c.out) - 1; i >= 0 && c.out[i] == '\n' && n > 0; i-- {
+		n--
+	}
+	c.write(strings.Repeat("\n", n))
+}
+
+/* Output of rendering as a single line, like for headers and table cells */
+func (c *converter) capture(render func()) string {
+	saved := c.out
+	c.out = nil
+	render()
+	captured := strings.Join(strings.Fields(string(c.out)), " ")
+	c.out = saved
+	return captured
+}
+
+func (c *converter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.render(child)
+	}
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func (c *converter) resolve(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return c.base.ResolveReference(u).String()
+}
+
+/* Text of preformatted block, lines wrapped in block elements are kept apart */
+func preText(n *html.Node, b *bytes.Buffer) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+	case html.ElementNode:
+		if n.Data == "br" {
+			b.WriteString("\n")
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			preText(child, b)
+		}
+		if (n.Data == "div" || n.Data == "p") && !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+			b.WriteString("\n")
+		}
+	}
+}
+
+/* Whitespace of text is collapsed, it separates words unless it's at the start of a line */
+func (c *converter) text(s string) {
+	space := func() {
+		if !c.atLineStart() && !bytes.HasSuffix(c.out, []byte(" ")) {
+			c.write(" ")
+		}
+	}
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if len(s) > 0 {
+			space()
+		}
+		return
+	}
+	if strings.TrimLeft(s, " \t\r\n") != s {
+		space()
+	}
+	c.write(strings.Join(words, " "))
+	if strings.TrimRight(s, " \t\r\n") != s {
+		c.write(" ")
+	}
+}
+
+func (c *converter) wrap(marker string, n *html.Node) {
+	content := c.capture(func() { c.children(n) })
+	if len(content) > 0 {
+		c.write(marker + content + marker)
+	}
+}
+
+func (c *converter) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.text(n.Data)
+		return
+	case html.DocumentNode:
+		c.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if skippedElements[n.Data] || strings.Contains(attr(n, "class"), "btn-copy") {
+		return
+	}
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Data[1:])
+		header := c.capture(func() { c.children(n) })
+		c.newlines(2)
+		c.write(strings.Repeat("#", level) + " " + header)
+		c.newlines(2)
+	case "p", "div", "section", "blockquote", "center":
+		if len(c.lists) > 0 {
+			c.children(n)
+			return
+		}
+		c.newlines(2)
+		c.children(n)
+		c.newlines(2)
+	case "br":
+		/* trailing spaces make a hard line break */
+		c.out = bytes.TrimRight(c.out, " \t")
+		c.write("  \n")
+	case "hr":
+		c.newlines(2)
+		c.write("---")
+		c.newlines(2)
+	case "pre":
+		var b bytes.Buffer
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			preText(child, &b)
+		}
+		c.newlines(2)
+		c.write("```\n" + strings.TrimRight(b.String(), "\n") + "\n```")
+		c.newlines(2)
+	case "code", "tt":
+		c.wrap("`", n)
+	case "b", "strong":
+		c.wrap("**", n)
+	case "i", "em":
+		c.wrap("*", n)
+	case "var":
+		content := c.capture(func() { c.children(n) })
+		if strings.Contains(content, "$") {
+			c.write(content)
+		} else if len(content) > 0 {
+			c.write("$" + content + "$")
+		}
+	case "a":
+		content := c.capture(func() { c.children(n) })
+		href := attr(n, "href")
+		if len(href) == 0 || strings.HasPrefix(href, "#") || len(content) == 0 {
+			c.write(content)
+			return
+		}
+		c.write("[" + content + "](" + c.resolve(href) + ")")
+	case "img":
+		src := attr(n, "src")
+		if len(src) == 0 {
+			return
+		}
+		c.write("![" + attr(n, "alt") + "](" + c.image(c.resolve(src)) + ")")
+	case "ul", "ol":
+		if len(c.lists) == 0 {
+			c.newlines(2)
+		}
+		c.lists = append(c.lists, list{ordered: n.Data == "ol"})
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		if len(c.lists) == 0 {
+			c.newlines(2)
+		}
+	case "li":
+		if len(c.lists) == 0 {
+			c.children(n)
+			return
+		}
+		current := &c.lists[len(c.lists)-1]
+		current.count++
+		marker := "- "
+		if current.ordered {
+			marker = strconv.Itoa(current.count) + ". "
+		}
+		c.newlines(1)
+		c.write(strings.Repeat("  ", len(c.lists)-1) + marker)
+		c.children(n)
+	case "table":
+		c.newlines(2)
+		c.table(n)
+		c.newlines(2)
+	default:
+		c.children(n)
+	}
+}
+
+/* Rows of the table, the first one is the header */
+func (c *converter) table(n *html.Node) {
+	var rows [][]string
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			if child.Data != "tr" {
+				collect(child)
+				continue
+			}
+			var row []string
+			for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+					content := c.capture(func() { c.children(cell) })
+					row = append(row, strings.Replace(content, "|", "\\|", -1))
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	collect(n)
+	for i, row := range rows {
+		c.write("| " + strings.Join(row, " | ") + " |")
+		c.newlines(1)
+		if i == 0 {
+			c.write(strings.Repeat("|---", len(row)) + "|")
+			c.newlines(1)
+		}
+	}
+}
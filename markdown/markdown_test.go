@@ -0,0 +1,54 @@
+package markdown
+
+import (
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		name     string
+		html     string
+		markdown string
+	}{
+		{"paragraphs", "<p>First   line\n of text.</p><p>Second.</p>", "First line of text.\n\nSecond.\n"},
+		{"header", "<h3>Input <span>format</span></h3><p>Text</p>", "### Input format\n\nText\n"},
+		{"inline", "<p>Print <b>YES</b>, <i>maybe</i> or <code>NO</code>.</p>", "Print **YES**, *maybe* or `NO`.\n"},
+		{"formula", "<p>Given $$$n$$$ and $a_i$.</p>", "Given $$$n$$$ and $a_i$.\n"},
+		{"var", "<p>Given <var>N</var> and <var>$K$</var>.</p>", "Given $N$ and $K$.\n"},
+		{"line break", "<p>one<br>two</p>", "one  \ntwo\n"},
+		{"rule", "<p>a</p><hr><p>b</p>", "a\n\n---\n\nb\n"},
+		{"pre", "<p>Sample</p><pre>1 2\n  3\n</pre>", "Sample\n\n```\n1 2\n  3\n```\n"},
+		{"pre lines", `<pre><div class="test-example-line">1</div><div class="test-example-line">2 3</div></pre>`, "```\n1\n2 3\n```\n"},
+		{"pre entities", "<pre>a &lt; b</pre>", "```\na < b\n```\n"},
+		{"relative link", `<p>See <a href="/blog/entry/1">blog</a>.</p>`, "See [blog](https://codeforces.com/blog/entry/1).\n"},
+		{"anchor", `<p><a href="#note">note</a></p>`, "note\n"},
+		{"image", `<p><img src="images/1.png" alt="tree"></p>`, "![tree](local:https://codeforces.com/contest/1/problem/images/1.png)\n"},
+		{"unordered list", "<ul><li>one</li><li>two <b>bold</b></li></ul>", "- one\n- two **bold**\n"},
+		{"ordered list", "<ol><li>one</li><li><p>two</p></li></ol>", "1. one\n2. two\n"},
+		{"nested list", "<ul><li>a<ol><li>b</li><li>c</li></ol></li><li>d</li></ul>", "- a\n  1. b\n  2. c\n- d\n"},
+		{"table", "<table><tr><th>n</th><th>answer</th></tr><tr><td>1</td><td>a|b</td></tr></table>",
+			"| n | answer |\n|---|---|\n| 1 | a\\|b |\n"},
+		{"skipped", `<p>Text<script>alert(1)</script><button>x</button></p><div class="btn-copy">Copy</div>`, "Text\n"},
+		{"empty", "", "\n"},
+	}
+	image := func(link string) string {
+		return "local:" + link
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			markdown, err := Convert(c.html, "https://codeforces.com/contest/1/problem/A", image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if markdown != c.markdown {
+				t.Errorf("expected %q, got %q", c.markdown, markdown)
+			}
+		})
+	}
+}
+
+func TestConvertBadBase(t *testing.T) {
+	if _, err := Convert("<p>x</p>", "://bad", nil); err == nil {
+		t.Errorf("bad base is accepted")
+	}
+}
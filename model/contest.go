@@ -6,34 +6,137 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type Test struct {
 	Token  string
 	Input  string
 	Output string
+	Group  string /* empty for samples */
+	/* test case of every input line in samples with several test cases, nil if not marked */
+	InputLineGroups []int
+}
+
+/* Specification of the way to check solution output, see package checker */
+type Checker struct {
+	Mode    string
+	Epsilon float64
+	Program string
 }
 
 type Task struct {
-	Link       string
-	Name       string
-	Token      string
-	TestTokens []string
+	Link        string
+	Name        string
+	Token       string
+	TestTokens  []string
+	TestGroups  map[string][]string /* tests run only on request, like hidden ones, by group name */
+	TimeLimit   time.Duration
+	MemoryLimit uint64 /* megabytes, zero if unknown */
+	InputFile   string /* empty for standard input */
+	OutputFile  string /* empty for standard output */
+	Checker     Checker
+	Interactive bool
 }
 
 type Contest struct {
-	Link    string
-	Name    string
-	Tasks   map[string]Task
-	RootDir string
+	Link      string
+	Name      string
+	Tasks     map[string]Task
+	RootDir   string
+	StartTime time.Time /* zero if unknown */
+}
+
+/* Credential which a platform may ask for, like user name or API key */
+type CredentialField struct {
+	Key      string
+	Prompt   string
+	Secret   bool
+	Optional bool
 }
 
 type Platform interface {
+	Name() string
+	Credentials() []CredentialField
 	ValidUrl(url string) bool
 	GetContest(url string, root_dirname string) (*Contest, error)
+	/* Start time is known before the contest starts, unlike its tasks */
+	GetStartTime(url string) (time.Time, error)
 	GetTests(task *Task) ([]Test, error)
 }
 
+/* State of a submission on the platform, it's Final once the verdict won't change */
+type SubmissionStatus struct {
+	Id           string
+	Verdict      string
+	Tests        int /* tests judged so far, if the platform reports it */
+	Final        bool
+	Accepted     bool
+	CompileError bool
+}
+
+/* Implemented by platforms accepting solutions */
+type Submitter interface {
+	/* Platform-specific ID of language, like "c++11" or "python3" */
+	LanguageId(language string) (string, bool)
+	Submit(task *Task, languageId string, source string) (string, error)
+	GetSubmission(task *Task, id string) (*SubmissionStatus, error)
+}
+
+type Submission struct {
+	Id          string
+	Task        string /* token of the task */
+	Language    string
+	Verdict     string
+	Time        time.Duration
+	Memory      uint64 /* bytes */
+	SubmittedAt time.Time
+}
+
+type StandingsRow struct {
+	Rank    int
+	Party   string
+	Points  float64
+	Penalty int
+	Solved  []string /* tokens of solved tasks */
+}
+
+type Standings struct {
+	Tasks   []string       /* tokens of tasks in order of the platform */
+	Solves  map[string]int /* number of participants solved the task, nil if unknown */
+	Leaders []StandingsRow
+	Mine    *StandingsRow /* nil if the user doesn't participate */
+}
+
+/* Implemented by platforms showing progress of the contest */
+type ContestTracker interface {
+	GetSubmissions(contest *Contest) ([]Submission, error)
+	/* standings with the given number of leaders */
+	GetStandings(contest *Contest, leaders int) (*Standings, error)
+}
+
+/* Implemented by platforms giving out full sets of tests, their tests are put into groups */
+type TestArchive interface {
+	GetTestArchive(task *Task) ([]Test, error)
+}
+
+/* Statement of the task as HTML fragment, relative links in it are resolved against Link */
+type Statement struct {
+	Html string
+	Link string
+}
+
+/* Implemented by platforms giving out statements for offline reading */
+type StatementPlatform interface {
+	GetStatement(task *Task) (*Statement, error)
+}
+
+/* Implemented by platforms which keep a session between runs */
+type SessionPlatform interface {
+	Login(credentials map[string]string) error
+	Logout() error
+}
+
 const root_file = ".contest.json"
 
 func GetRootFile(contest *Contest) string {
@@ -45,7 +148,30 @@ func SaveContest(contest *Contest) error {
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(GetRootFile(contest), b, 0644)
+	/* write to a temporary file first, so metadata is never left half-written */
+	path := GetRootFile(contest)
+	tmp, err := ioutil.TempFile(filepath.Dir(path), root_file+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
 }
 
 func LoadContest(path string) (*Contest, error) {
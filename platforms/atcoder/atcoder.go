@@ -1,45 +1,122 @@
 package atcoder
 
 import (
-	"bufio"
 	"fmt"
 	"log"
-	"os"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/headzoo/surf/jar"
+	"github.com/headzoo/surf/browser"
+	"github.com/mxwell/wac/credentials"
 	"github.com/mxwell/wac/model"
-	"golang.org/x/crypto/ssh/terminal"
-	"gopkg.in/headzoo/surf.v1"
+	"github.com/mxwell/wac/util"
 )
 
 type AtCoder struct {
 }
 
+/* Name of the file with saved cookies */
+const SessionName = "atcoder"
+
+var session = util.NewSession(SessionName)
+
+/* Contests joined during this run */
+var joined = make(map[string]bool)
+var joinMutex sync.Mutex
+
 func InitAtCoder() model.Platform {
 	return AtCoder{}
 }
 
+func (a AtCoder) Name() string {
+	return "atcoder"
+}
+
+var usernameField = model.CredentialField{Key: "username", Prompt: "AtCoder user name"}
+
+var credentialFields = []model.CredentialField{
+	usernameField,
+	{Key: "password", Prompt: "AtCoder password", Secret: true},
+}
+
+func (a AtCoder) Credentials() []model.CredentialField {
+	return credentialFields
+}
+
 func (a AtCoder) ValidUrl(link string) bool {
-	_, err := trimUrl(link)
+	_, err := parseUrl(link)
 	return err == nil
 }
 
 func (a AtCoder) GetContest(link string, rootDirName string) (*model.Contest, error) {
-	link, err := trimUrl(link)
+	site, err := parseUrl(link)
 	if err != nil {
 		return nil, err
 	}
-	doc, err := retrieveDocument(link + "/assignments")
+	doc, err := retrieveDocument(site.tasksLink())
 	if err != nil {
 		return nil, err
 	}
+	var title string
+	var tasks map[string]model.Task
+	if site.legacy {
+		title, tasks, err = parseLegacyTasks(doc, site.base)
+	} else {
+		title, tasks, err = parseTasks(doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	contest := &model.Contest{Link: site.base, Name: title, Tasks: tasks, RootDir: rootDirName}
+	if !site.legacy {
+		if start, err := parseStartTime(doc); err == nil {
+			contest.StartTime = start
+		} else {
+			log.Printf("WARN unable to get start time of the contest: %s\n", err)
+		}
+	}
+	return contest, nil
+}
+
+func (a AtCoder) GetStartTime(link string) (time.Time, error) {
+	site, err := parseUrl(link)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if site.legacy {
+		return time.Time{}, fmt.Errorf("start time is not available on the legacy site")
+	}
+	doc, err := retrieveDocument(site.base)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseStartTime(doc)
+}
+
+/* Pages of the contest show its duration in the header, like "2019-04-06 21:00:00+0900 - ..." */
+func parseStartTime(doc *goquery.Selection) (time.Time, error) {
+	element := doc.Find("small.contest-duration time").First()
+	if element.Length() == 0 {
+		return time.Time{}, fmt.Errorf("contest duration is not found")
+	}
+	value := strings.TrimSpace(element.Text())
+	start, err := time.Parse("2006-01-02 15:04:05-0700", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected start time '%s'", value)
+	}
+	return start, nil
+}
+
+/* Task list of the legacy site at /assignments */
+func parseLegacyTasks(doc *goquery.Selection, base string) (string, map[string]model.Task, error) {
 	titleElement := doc.Find("span.contest-name")
 	if titleElement.Length() != 1 {
-		return nil, fmt.Errorf("unable to detect contest name")
+		return "", nil, fmt.Errorf("unable to detect contest name")
 	}
 	title := titleElement.Text()
 	tasks := make(map[string]model.Task)
@@ -71,9 +148,58 @@ func (a AtCoder) GetContest(link string, rootDirName string) (*model.Contest, er
 			return
 		}
 		name := nameElement.Text()
-		tasks[token] = model.Task{link + href, name, token, make([]string, 0)}
+		tasks[token] = model.Task{Link: base + href, Name: name, Token: token, TestTokens: make([]string, 0)}
+	})
+	return title, tasks, nil
+}
+
+/* Task list of the current site at /contests/<id>/tasks */
+func parseTasks(doc *goquery.Selection) (string, map[string]model.Task, error) {
+	titleElement := doc.Find("a.contest-title")
+	if titleElement.Length() != 1 {
+		return "", nil, fmt.Errorf("unable to detect contest name")
+	}
+	title := strings.TrimSpace(titleElement.Text())
+	tasks := make(map[string]model.Task)
+	doc.Find("table tbody tr").Each(func(i int, s *goquery.Selection) {
+		columns := s.Find("td")
+		if columns.Length() < 2 {
+			log.Printf("WARN unable to parse task %d\n", i)
+			return
+		}
+		tokenElement := columns.Eq(0).Find("a")
+		if tokenElement.Length() != 1 {
+			log.Printf("WARN unable to find token of task %d\n", i)
+			return
+		}
+		token := strings.ToLower(strings.TrimSpace(tokenElement.Text()))
+		nameElement := columns.Eq(1).Find("a")
+		if nameElement.Length() != 1 {
+			log.Printf("WARN unable to find name of task %d\n", i)
+			return
+		}
+		href, ok := nameElement.Attr("href")
+		if !ok {
+			log.Printf("WARN unable to extract link to task %d\n", i)
+			return
+		}
+		name := strings.TrimSpace(nameElement.Text())
+		task := model.Task{Link: AtCoderHost + href, Name: name, Token: token, TestTokens: make([]string, 0)}
+		/* columns after the name hold limits, like "2 sec" and "1024 MB" */
+		if columns.Length() >= 4 {
+			if limit, err := parseTimeLimit(columns.Eq(2).Text()); err == nil {
+				task.TimeLimit = limit
+			}
+			if limit, err := parseMemoryLimit(columns.Eq(3).Text()); err == nil {
+				task.MemoryLimit = limit
+			}
+		}
+		tasks[token] = task
 	})
-	return &model.Contest{link, title, tasks, rootDirName}, nil
+	if len(tasks) == 0 {
+		return "", nil, fmt.Errorf("no tasks are found")
+	}
+	return title, tasks, nil
 }
 
 func contains(arr *[]int, value int) bool {
@@ -94,47 +220,9 @@ func (a AtCoder) GetTests(task *model.Task) ([]model.Test, error) {
 	if statementElement.Length() != 1 {
 		return nil, fmt.Errorf("can't detect task-statement uniquely: %d item(s) found", statementElement.Length())
 	}
-	enSpanElement := statementElement.Find("span.lang-en")
-	if enSpanElement.Length() != 1 {
-		return nil, fmt.Errorf("can't detect span in English uniquely")
-	}
-
-	sampleInputs := make(map[int]string)
-	sampleOutputs := make(map[int]string)
-	/* add sample IDs in order of appearance to restore the order later */
-	var idOrder []int
-
-	enSpanElement.Find("div.part").Each(func(i int, s *goquery.Selection) {
-		headerElement := s.Find("section h3")
-		if headerElement.Length() != 1 {
-			return
-		}
-		preElement := s.Find("section pre")
-		if preElement.Length() != 1 {
-			return
-		}
-		header := headerElement.Text()
-		pre := preElement.Text()
-		if strings.HasPrefix(header, "Sample Input ") {
-			if id, err := strconv.Atoi(strings.TrimPrefix(header, "Sample Input ")); err == nil {
-				sampleInputs[id] = pre
-				if !contains(&idOrder, id) {
-					idOrder = append(idOrder, id)
-				}
-			} else {
-				log.Printf("WARN can't parse sample id from header '%s'\n", header)
-			}
-		} else if strings.HasPrefix(header, "Sample Output ") {
-			if id, err := strconv.Atoi(strings.TrimPrefix(header, "Sample Output ")); err == nil {
-				sampleOutputs[id] = pre
-				if !contains(&idOrder, id) {
-					idOrder = append(idOrder, id)
-				}
-			} else {
-				log.Printf("WARN can't parse sample id from header '%s'\n", header)
-			}
-		}
-	})
+	task.Interactive = isInteractive(statementElement)
+	parseLimits(doc, task)
+	sampleInputs, sampleOutputs, idOrder := parseSamples(statementSection(statementElement))
 
 	var result []model.Test
 	for _, id := range idOrder {
@@ -145,108 +233,322 @@ func (a AtCoder) GetTests(task *model.Task) ([]model.Test, error) {
 			continue
 		}
 		token := "sample" + strconv.Itoa(id)
-		result = append(result, model.Test{token, input, output})
+		result = append(result, model.Test{Token: token, Input: input, Output: output})
 	}
 
 	if len(result) == 0 {
+		if task.Interactive {
+			log.Printf("WARN no sample tests for interactive task %s\n", task.Token)
+			return nil, nil
+		}
 		return nil, fmt.Errorf("tests are not found")
 	}
 
 	return result, nil
 }
 
-const SchemeHttp = "http://"
-const SchemeHttps = "https://"
+func (a AtCoder) GetStatement(task *model.Task) (*model.Statement, error) {
+	doc, err := retrieveDocument(task.Link)
+	if err != nil {
+		return nil, err
+	}
+	statementElement := doc.Find("#task-statement")
+	if statementElement.Length() != 1 {
+		return nil, fmt.Errorf("can't detect task-statement uniquely: %d item(s) found", statementElement.Length())
+	}
+	content, err := statementSection(statementElement).Html()
+	if err != nil {
+		return nil, err
+	}
+	return &model.Statement{Html: content, Link: task.Link}, nil
+}
+
+/* Statement in English, or in Japanese when there is no translation, like in many older contests */
+func statementSection(statement *goquery.Selection) *goquery.Selection {
+	for _, selector := range []string{"span.lang-en", "span.lang-ja"} {
+		if section := statement.Find(selector); section.Length() == 1 {
+			return section
+		}
+	}
+	/* the oldest statements aren't split by languages */
+	return statement
+}
+
+var sampleHeaderRegexp = regexp.MustCompile(`^(Sample Input|Sample Output|入力例|出力例)\s*([0-9]*)`)
 
-func trimUrl(link string) (string, error) {
-	var offset int
-	if strings.HasPrefix(link, SchemeHttp) {
-		offset = len(SchemeHttp)
-	} else if strings.HasPrefix(link, SchemeHttps) {
-		offset = len(SchemeHttps)
-	} else {
-		return "", fmt.Errorf("no valid scheme is detected in url - %s", link)
+/* Digits are sometimes full-width in Japanese headers, like "入力例 １" */
+var digitReplacer = strings.NewReplacer("０", "0", "１", "1", "２", "2", "３", "3", "４", "4", "５", "5", "６", "6", "７", "7", "８", "8", "９", "9")
+
+/* Kind of the sample header, like "Sample Input 1" or "入力例 1", and the sample ID */
+func parseSampleHeader(header string) (input bool, id int, ok bool) {
+	match := sampleHeaderRegexp.FindStringSubmatch(strings.TrimSpace(digitReplacer.Replace(header)))
+	if match == nil {
+		return false, 0, false
 	}
-	slash := strings.Index(link[offset:], "/")
-	if slash >= 0 {
-		link = link[:offset+slash]
+	input = match[1] == "Sample Input" || match[1] == "入力例"
+	if len(match[2]) == 0 {
+		return input, 0, true
 	}
-	if !strings.HasSuffix(link[offset:], ".contest.atcoder.jp") {
-		return "", fmt.Errorf("bad contest URL")
+	id, err := strconv.Atoi(match[2])
+	return input, id, err == nil
+}
+
+/*
+ * Block of the sample following the header. A part may hold several blocks,
+ * like an illustration after the sample, so the one with the copy button is
+ * preferred, then the first one.
+ */
+func samplePre(header *goquery.Selection) *goquery.Selection {
+	following := header.NextUntil("h3")
+	pres := following.Filter("pre").AddSelection(following.Find("pre"))
+	if marked := pres.Filter(`[id^="pre-sample"]`); marked.Length() > 0 {
+		return marked.First()
 	}
-	return link, nil
+	return pres.First()
 }
 
-type Credentials struct {
-	name     string
-	password string
+/* Samples by ID and the IDs in order of appearance, headers without numbers are counted */
+func parseSamples(section *goquery.Selection) (map[int]string, map[int]string, []int) {
+	sampleInputs := make(map[int]string)
+	sampleOutputs := make(map[int]string)
+	var idOrder []int
+	section.Find("h3").Each(func(i int, s *goquery.Selection) {
+		header := s.Text()
+		input, id, ok := parseSampleHeader(header)
+		if !ok {
+			return
+		}
+		target := sampleOutputs
+		if input {
+			target = sampleInputs
+		}
+		if id == 0 {
+			id = len(target) + 1
+		}
+		pre := samplePre(s)
+		if pre.Length() == 0 {
+			log.Printf("WARN no sample after header '%s'\n", strings.TrimSpace(header))
+			return
+		}
+		target[id] = pre.Text()
+		if !contains(&idOrder, id) {
+			idOrder = append(idOrder, id)
+		}
+	})
+	return sampleInputs, sampleOutputs, idOrder
 }
 
-func getCredentials() (*Credentials, error) {
-	reader := bufio.NewReader(os.Stdin)
+var timeLimitRegexp = regexp.MustCompile(`(?i)(?:time limit|実行時間制限)\s*:\s*([0-9.]+\s*sec)`)
+var memoryLimitRegexp = regexp.MustCompile(`(?i)(?:memory limit|メモリ制限)\s*:\s*([0-9]+\s*[KM]i?B)`)
 
-	fmt.Print("Enter AtCoder name: ")
-	name, _ := reader.ReadString('\n')
+/* Value like "2 sec" */
+func parseTimeLimit(value string) (time.Duration, error) {
+	number := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "sec"))
+	seconds, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected time limit '%s'", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
 
-	fmt.Print("Enter AtCoder password: ")
-	buffer, err := terminal.ReadPassword(int(syscall.Stdin))
+/* Value like "1024 MB" or "64000 KB", result is in megabytes */
+func parseMemoryLimit(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	unit := strings.TrimLeft(value, "0123456789 ")
+	amount, err := strconv.ParseUint(strings.TrimSpace(strings.TrimSuffix(value, unit)), 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read password: %s", err)
+		return 0, fmt.Errorf("unexpected memory limit '%s'", value)
 	}
-	password := string(buffer)
+	switch strings.ToUpper(unit) {
+	case "MB", "MIB":
+		return amount, nil
+	case "KB", "KIB":
+		return (amount + 1023) / 1024, nil
+	}
+	return 0, fmt.Errorf("unexpected memory limit '%s'", value)
+}
 
-	name = strings.TrimSpace(name)
-	password = strings.TrimSpace(password)
+/* Limits are given above the statement, like "Time Limit: 2 sec / Memory Limit: 1024 MB" */
+func parseLimits(doc *goquery.Selection, task *model.Task) {
+	text := doc.Text()
+	if match := timeLimitRegexp.FindStringSubmatch(text); match != nil {
+		if limit, err := parseTimeLimit(match[1]); err == nil {
+			task.TimeLimit = limit
+		} else {
+			log.Printf("WARN %s\n", err)
+		}
+	}
+	if match := memoryLimitRegexp.FindStringSubmatch(text); match != nil {
+		if limit, err := parseMemoryLimit(match[1]); err == nil {
+			task.MemoryLimit = limit
+		} else {
+			log.Printf("WARN %s\n", err)
+		}
+	}
+}
 
-	return &Credentials{name, password}, nil
+/* Statements of interactive tasks say so explicitly */
+func isInteractive(statement *goquery.Selection) bool {
+	text := statement.Text()
+	return strings.Contains(text, "interactive task") || strings.Contains(text, "インタラクティブ")
 }
 
-func retrieveDocument(link string) (*goquery.Selection, error) {
-	base, err := trimUrl(link)
-	if err != nil {
-		return nil, err
+/* Host could be changed with WAC_ATCODER_HOST, like to a local fake judge */
+var AtCoderHost = util.PlatformHost("atcoder", "https://atcoder.jp")
+
+/*
+ * Contest is either on the legacy site at <id>.contest.atcoder.jp
+ * or on the current one at atcoder.jp/contests/<id>
+ */
+type contestSite struct {
+	base   string
+	legacy bool
+}
+
+func (s *contestSite) tasksLink() string {
+	if s.legacy {
+		return s.base + "/assignments"
 	}
-	loginLink := base + "/login"
-	cred, err := getCredentials()
+	return s.base + "/tasks"
+}
+
+func (s *contestSite) loginLink() string {
+	if s.legacy {
+		return s.base + "/login"
+	}
+	return AtCoderHost + "/login"
+}
+
+func parseUrl(link string) (*contestSite, error) {
+	page, err := util.ParsePageLink(link)
 	if err != nil {
 		return nil, err
 	}
+	if strings.HasSuffix(page.Host, ".contest.atcoder.jp") {
+		return &contestSite{base: page.Base(), legacy: true}, nil
+	}
+	if page.Host == "atcoder.jp" || page.Host == "www.atcoder.jp" || page.Host == util.HostOf(AtCoderHost) {
+		parts := page.Parts()
+		if len(parts) >= 2 && parts[0] == "contests" && len(parts[1]) > 0 {
+			return &contestSite{base: AtCoderHost + "/contests/" + parts[1]}, nil
+		}
+	}
+	return nil, fmt.Errorf("bad contest URL")
+}
+
+func isLoginUrl(u *url.URL) bool {
+	return strings.HasSuffix(u.Path, "/login")
+}
 
-	bow := surf.NewBrowser()
-	cookieJar := jar.NewMemoryCookies()
-	bow.SetCookieJar(cookieJar)
-	err = bow.Open(loginLink)
+func isLoginPage(bow *browser.Browser) bool {
+	return isLoginUrl(bow.Url())
+}
+
+func login(bow *browser.Browser, site *contestSite, cred map[string]string) error {
+	loginLink := site.loginLink()
+	err := bow.Open(loginLink)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch login page - %s: %s", loginLink, err)
+		return fmt.Errorf("failed to fetch login page - %s: %s", loginLink, err)
 	}
-	fm, _ := bow.Form("form.form-horizontal")
-	fm.Input("name", cred.name)
-	fm.Input("password", cred.password)
-	err = fm.Submit()
-	if err != nil {
-		return nil, fmt.Errorf("failed to submit login form at %s: %s", loginLink, err)
+	if site.legacy {
+		fm, err := bow.Form("form.form-horizontal")
+		if err != nil {
+			return fmt.Errorf("failed to find login form at %s: %s", loginLink, err)
+		}
+		fm.Input("name", cred["username"])
+		fm.Input("password", cred["password"])
+		err = fm.Submit()
+		if err != nil {
+			return fmt.Errorf("failed to submit login form at %s: %s", loginLink, err)
+		}
+		privilege := ""
+		for _, cookie := range bow.SiteCookies() {
+			if cookie.Name == "__privilege" {
+				privilege = cookie.Value
+			}
+		}
+		if len(privilege) == 0 {
+			return fmt.Errorf("failed to gain privilege after login")
+		}
+	} else {
+		/* the form is rejected without the token issued with the page */
+		csrfToken, ok := bow.Dom().Find(`input[name="csrf_token"]`).First().Attr("value")
+		if !ok {
+			return fmt.Errorf("failed to find CSRF token at %s", loginLink)
+		}
+		fm, err := bow.Form(`form:has(input[name="csrf_token"])`)
+		if err != nil {
+			return fmt.Errorf("failed to find login form at %s: %s", loginLink, err)
+		}
+		fm.Input("username", cred["username"])
+		fm.Input("password", cred["password"])
+		fm.Input("csrf_token", csrfToken)
+		err = fm.Submit()
+		if err != nil {
+			return fmt.Errorf("failed to submit login form at %s: %s", loginLink, err)
+		}
+		if isLoginPage(bow) {
+			return fmt.Errorf("login is rejected, check user name and password")
+		}
 	}
+	return nil
+}
 
-	privilege := ""
-	for _, cookie := range cookieJar.Cookies(bow.Url()) {
-		if cookie.Name == "__privilege" {
-			privilege = cookie.Value
+/* Login on the site with saved credentials, used when there is no valid session */
+func loginWithCredentials(site *contestSite) func(bow *browser.Browser) error {
+	return func(bow *browser.Browser) error {
+		cred, err := credentials.Get(SessionName, credentialFields)
+		if err != nil {
+			return err
 		}
+		return login(bow, site, cred)
 	}
-	if len(privilege) == 0 {
-		return nil, fmt.Errorf("failed to gain privilege after login")
-	}
+}
+
+/* Open the page, logging in first if there is no valid session */
+func openLoggedIn(bow *browser.Browser, site *contestSite, link string) error {
+	return session.OpenLoggedIn(bow, link, isLoginPage, loginWithCredentials(site))
+}
+
+/* Download the response which isn't a page, like JSON, logging in first if there is no valid session */
+func downloadLoggedIn(site *contestSite, link string) ([]byte, error) {
+	return session.DownloadLoggedIn(link, isLoginUrl, loginWithCredentials(site))
+}
 
-	joinLink := base + "/participants/insert"
-	err = bow.Open(joinLink)
+func retrieveDocument(link string) (*goquery.Selection, error) {
+	site, err := parseUrl(link)
 	if err != nil {
-		return nil, fmt.Errorf("failed to join the contest by link %s: %s", joinLink, err)
+		return nil, err
 	}
-
-	err = bow.Open(link)
+	bow, err := session.NewBrowser()
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch requested page - %s: %s", link, err)
+		return nil, err
+	}
+	/* contests of the legacy site show tasks only to participants */
+	if site.legacy {
+		joinMutex.Lock()
+		if !joined[site.base] {
+			joinLink := site.base + "/participants/insert"
+			if err := openLoggedIn(bow, site, joinLink); err != nil {
+				joinMutex.Unlock()
+				return nil, fmt.Errorf("failed to join the contest by link %s: %s", joinLink, err)
+			}
+			joined[site.base] = true
+		}
+		joinMutex.Unlock()
+	}
+	if err := openLoggedIn(bow, site, link); err != nil {
+		return nil, err
 	}
-
 	return bow.Dom(), nil
 }
+
+func (a AtCoder) Login(cred map[string]string) error {
+	return session.Login(func(bow *browser.Browser) error {
+		return login(bow, &contestSite{base: AtCoderHost}, cred)
+	})
+}
+
+func (a AtCoder) Logout() error {
+	return session.Remove()
+}
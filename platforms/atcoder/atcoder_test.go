@@ -0,0 +1,76 @@
+package atcoder
+
+import (
+	"testing"
+)
+
+func TestParseUrl(t *testing.T) {
+	cases := []struct {
+		link   string
+		base   string
+		legacy bool
+	}{
+		{"https://atcoder.jp/contests/abc100", "https://atcoder.jp/contests/abc100", false},
+		{"https://atcoder.jp/contests/abc100/tasks/abc100_a", "https://atcoder.jp/contests/abc100", false},
+		{"https://atcoder.jp/contests/abc100/", "https://atcoder.jp/contests/abc100", false},
+		{"http://www.atcoder.jp/contests/arc090?lang=en", "https://atcoder.jp/contests/arc090", false},
+		{"https://atcoder.jp/contests/agc001#top", "https://atcoder.jp/contests/agc001", false},
+		{"https://abc042.contest.atcoder.jp", "https://abc042.contest.atcoder.jp", true},
+		{"http://arc055.contest.atcoder.jp/tasks/arc055_a", "http://arc055.contest.atcoder.jp", true},
+	}
+	for _, c := range cases {
+		site, err := parseUrl(c.link)
+		if err != nil {
+			t.Errorf("%s: %s", c.link, err)
+			continue
+		}
+		if site.base != c.base || site.legacy != c.legacy {
+			t.Errorf("%s: expected %s (legacy %v), got %s (legacy %v)", c.link, c.base, c.legacy, site.base, site.legacy)
+		}
+	}
+}
+
+func TestParseUrlErrors(t *testing.T) {
+	for _, link := range []string{
+		"atcoder.jp/contests/abc100",
+		"https://atcoder.jp",
+		"https://atcoder.jp/contests",
+		"https://atcoder.jp/contests/",
+		"https://atcoder.jp/users/tourist",
+		"https://codeforces.com/contest/100",
+		"https://contest.atcoder.jp.example.com/contests/abc100",
+	} {
+		if site, err := parseUrl(link); err == nil {
+			t.Errorf("%s: expected error, got %+v", link, site)
+		}
+	}
+}
+
+func TestParseSampleHeader(t *testing.T) {
+	cases := []struct {
+		header string
+		input  bool
+		id     int
+		ok     bool
+	}{
+		{"Sample Input 1", true, 1, true},
+		{"Sample Output 1", false, 1, true},
+		{"Sample Input 12", true, 12, true},
+		{"  Sample Output 3 Copy", false, 3, true},
+		{"Sample Input", true, 0, true},
+		{"入力例 1", true, 1, true},
+		{"出力例 2", false, 2, true},
+		{"入力例１", true, 1, true},
+		{"出力例 ２３", false, 23, true},
+		{"Input", false, 0, false},
+		{"Constraints", false, 0, false},
+		{"入力", false, 0, false},
+		{"Explanation of Sample Input 1", false, 0, false},
+	}
+	for _, c := range cases {
+		input, id, ok := parseSampleHeader(c.header)
+		if input != c.input || id != c.id || ok != c.ok {
+			t.Errorf("%q: expected (%v, %d, %v), got (%v, %d, %v)", c.header, c.input, c.id, c.ok, input, id, ok)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package atcoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mxwell/wac/model"
+)
+
+/* IDs of compilers used for languages of wac config, others are given with --language-id */
+var languageIds = map[string]string{
+	"c++11":   "5001",
+	"python3": "5055",
+}
+
+/* Status labels of submissions which are still being judged, others are final */
+var pendingLabels = []string{"WJ", "WR", "Judging"}
+
+func (a AtCoder) LanguageId(language string) (string, bool) {
+	id, ok := languageIds[language]
+	return id, ok
+}
+
+/* Task of the current site with its screen name, like abc123_a */
+func taskSite(task *model.Task) (*contestSite, string, error) {
+	site, err := parseUrl(task.Link)
+	if err != nil {
+		return nil, "", err
+	}
+	if site.legacy {
+		return nil, "", fmt.Errorf("submission to the legacy site is not supported")
+	}
+	u, err := url.Parse(task.Link)
+	if err != nil {
+		return nil, "", err
+	}
+	return site, path.Base(u.Path), nil
+}
+
+func (a AtCoder) Submit(task *model.Task, languageId string, source string) (string, error) {
+	site, screenName, err := taskSite(task)
+	if err != nil {
+		return "", err
+	}
+	code, err := ioutil.ReadFile(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read source: %s", err)
+	}
+	bow, err := session.NewBrowser()
+	if err != nil {
+		return "", err
+	}
+	submitLink := site.base + "/submit"
+	if err := openLoggedIn(bow, site, submitLink); err != nil {
+		return "", err
+	}
+	csrfToken, ok := bow.Dom().Find(`input[name="csrf_token"]`).First().Attr("value")
+	if !ok {
+		return "", fmt.Errorf("failed to find CSRF token at %s", submitLink)
+	}
+	fm, err := bow.Form(`form:has(textarea[name="sourceCode"])`)
+	if err != nil {
+		return "", fmt.Errorf("failed to find submit form at %s: %s", submitLink, err)
+	}
+	/* Set, unlike Input, also fills selects which have no option selected */
+	fm.Set("data.TaskScreenName", screenName)
+	fm.Set("data.LanguageId", languageId)
+	fm.Set("sourceCode", string(code))
+	fm.Set("csrf_token", csrfToken)
+	if err := fm.Submit(); err != nil {
+		return "", fmt.Errorf("failed to submit form at %s: %s", submitLink, err)
+	}
+	if strings.HasSuffix(bow.Url().Path, "/submit") {
+		message := strings.TrimSpace(bow.Dom().Find("div.alert-danger").First().Text())
+		if len(message) == 0 {
+			message = "unknown reason"
+		}
+		return "", fmt.Errorf("submission is rejected: %s", message)
+	}
+	/* the page with own submissions is shown after submit, the newest is the first */
+	id, ok := bow.Dom().Find("td.submission-score[data-id]").First().Attr("data-id")
+	if !ok {
+		return "", fmt.Errorf("submission ID is not found at %s", bow.Url())
+	}
+	return id, nil
+}
+
+/* Response of /submissions/me/status/json, HTML holds cells of the submission row */
+type statusResponse struct {
+	Result map[string]struct {
+		Html  string
+		Score string
+	}
+}
+
+func (a AtCoder) GetSubmission(task *model.Task, id string) (*model.SubmissionStatus, error) {
+	site, _, err := taskSite(task)
+	if err != nil {
+		return nil, err
+	}
+	link := site.base + "/submissions/me/status/json?" + url.Values{"sids[]": {id}}.Encode()
+	body, err := downloadLoggedIn(site, link)
+	if err != nil {
+		return nil, err
+	}
+	var response statusResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("bad status of submission %s: %s", id, err)
+	}
+	result, ok := response.Result[id]
+	if !ok {
+		return nil, fmt.Errorf("submission %s is not found", id)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<table><tr>" + result.Html + "</tr></table>"))
+	if err != nil {
+		return nil, fmt.Errorf("bad status of submission %s: %s", id, err)
+	}
+	return parseStatusLabel(id, strings.TrimSpace(doc.Find("span.label").First().Text())), nil
+}
+
+/* Label is a verdict, like "AC" or "WA", or progress of judging, like "3/10" or "3/10 WA" */
+func parseStatusLabel(id string, label string) *model.SubmissionStatus {
+	status := &model.SubmissionStatus{Id: id, Verdict: label}
+	if slash := strings.Index(label, "/"); slash >= 0 {
+		fmt.Sscanf(label[:slash], "%d", &status.Tests)
+		return status
+	}
+	for _, pending := range pendingLabels {
+		if label == pending {
+			return status
+		}
+	}
+	status.Final = true
+	status.Accepted = label == "AC"
+	status.CompileError = label == "CE"
+	return status
+}
@@ -0,0 +1,150 @@
+package atcoder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+)
+
+/* Statuses shown by the fake judge on the consecutive polls */
+var fakeStatuses = []string{"WJ", "1/3", "2/3 WA", "AC"}
+
+/* Judge with pages and JSON of the current site which are used to submit and track a solution */
+type fakeJudge struct {
+	t      *testing.T
+	mutex  sync.Mutex
+	logins int
+	source string
+	polls  int
+}
+
+const fakeSubmissionId = "31415926"
+
+const fakeStandings = `{"TaskInfo":[{"Assignment":"A","TaskScreenName":"abc100_a"},{"Assignment":"B","TaskScreenName":"abc100_b"}],
+"StandingsData":[
+{"Rank":1,"UserScreenName":"<leader>","TotalResult":{"Score":60000,"Penalty":0},"TaskResults":{"abc100_a":{"Score":20000},"abc100_b":{"Score":40000}}},
+{"Rank":2,"UserScreenName":"tourist","TotalResult":{"Score":20000,"Penalty":1},"TaskResults":{"abc100_a":{"Score":20000},"abc100_b":{"Score":0}}}]}`
+
+func (j *fakeJudge) loggedIn(r *http.Request) bool {
+	cookie, err := r.Cookie("REVEL_SESSION")
+	return err == nil && cookie.Value == "tourist"
+}
+
+func (j *fakeJudge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if r.URL.Path != "/login" && !j.loggedIn(r) {
+		http.Redirect(w, r, "/login?continue="+r.URL.Path, http.StatusFound)
+		return
+	}
+	switch {
+	case r.URL.Path == "/login" && r.Method == "GET":
+		fmt.Fprint(w, `<html><body><form method="POST" action="">
+<input type="text" name="username"><input type="password" name="password">
+<input type="hidden" name="csrf_token" value="token">
+<button type="submit">Sign In</button></form></body></html>`)
+	case r.URL.Path == "/login":
+		if r.FormValue("csrf_token") != "token" || r.FormValue("username") != "tourist" || r.FormValue("password") != "secret" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		j.logins++
+		http.SetCookie(w, &http.Cookie{Name: "REVEL_SESSION", Value: "tourist", Path: "/"})
+		http.Redirect(w, r, "/home", http.StatusFound)
+	case r.URL.Path == "/home":
+		fmt.Fprint(w, `<html><body>Welcome</body></html>`)
+	case r.URL.Path == "/contests/abc100/submit" && r.Method == "GET":
+		fmt.Fprint(w, `<html><body><form class="form-horizontal" method="POST" action="/contests/abc100/submit">
+<select name="data.TaskScreenName"><option value="abc100_a">A - Happy Birthday!</option><option value="abc100_b">B - Ringo's Favorite Numbers</option></select>
+<select name="data.LanguageId"><option value="5001">C++ 20 (gcc 12.2)</option><option value="5055">Python (CPython 3.11.4)</option></select>
+<textarea name="sourceCode"></textarea>
+<input type="hidden" name="csrf_token" value="token">
+<button type="submit">Submit</button></form></body></html>`)
+	case r.URL.Path == "/contests/abc100/submit":
+		if r.FormValue("csrf_token") != "token" || r.FormValue("data.TaskScreenName") != "abc100_b" || r.FormValue("data.LanguageId") != "5055" {
+			j.t.Errorf("bad submission form: %v", r.PostForm)
+			http.Redirect(w, r, "/contests/abc100/submit", http.StatusFound)
+			return
+		}
+		j.source = r.FormValue("sourceCode")
+		http.Redirect(w, r, "/contests/abc100/submissions/me", http.StatusFound)
+	case r.URL.Path == "/contests/abc100/submissions/me":
+		fmt.Fprintf(w, `<html><body><table><tbody><tr>
+<td>2026-10-17 21:00:00+0900</td><td><a href="/contests/abc100/tasks/abc100_b">B</a></td>
+<td class="submission-score" data-id="%s">0</td><td><span class="label">WJ</span></td>
+</tr></tbody></table></body></html>`, fakeSubmissionId)
+	case r.URL.Path == "/contests/abc100/submissions/me/status/json":
+		id := r.URL.Query().Get("sids[]")
+		status := fakeStatuses[j.polls]
+		if j.polls+1 < len(fakeStatuses) {
+			j.polls++
+		}
+		fmt.Fprintf(w, `{"Result":{"%s":{"Html":"<td class='text-center'><span class='label label-default'>%s</span></td>","Score":"0"}},"Interval":500}`, id, status)
+	case r.URL.Path == "/contests/abc100/standings/json":
+		fmt.Fprint(w, fakeStandings)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+/* Platform host, session and credentials are pointed to the fake judge and a temporary home */
+func useFakeJudge(t *testing.T, judge http.Handler) string {
+	server := httptest.NewServer(judge)
+	t.Cleanup(server.Close)
+	savedHost, savedSession, savedInterval := AtCoderHost, session, util.HostInterval
+	t.Cleanup(func() { AtCoderHost, session, util.HostInterval = savedHost, savedSession, savedInterval })
+	AtCoderHost = server.URL
+	session = util.NewSession(SessionName)
+	util.HostInterval = 0
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("WAC_ATCODER_USERNAME", "tourist")
+	t.Setenv("WAC_ATCODER_PASSWORD", "secret")
+	return server.URL
+}
+
+func TestSubmitToFakeJudge(t *testing.T) {
+	judge := &fakeJudge{t: t}
+	host := useFakeJudge(t, judge)
+	source := filepath.Join(t.TempDir(), "main.py")
+	if err := ioutil.WriteFile(source, []byte("print(42)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	task := &model.Task{Link: host + "/contests/abc100/tasks/abc100_b", Token: "b"}
+	id, err := AtCoder{}.Submit(task, "5055", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != fakeSubmissionId {
+		t.Errorf("expected submission %s, got %s", fakeSubmissionId, id)
+	}
+	if judge.source != "print(42)\n" {
+		t.Errorf("source is not submitted: %q", judge.source)
+	}
+	/* expired session is renewed while polling */
+	if err := session.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	var status *model.SubmissionStatus
+	for i := 0; i < 2*len(fakeStatuses) && (status == nil || !status.Final); i++ {
+		status, err = AtCoder{}.GetSubmission(task, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && (status.Final || status.Tests != 1) {
+			t.Errorf("progress is not recognized: %+v", status)
+		}
+	}
+	if !status.Final || !status.Accepted || status.Verdict != "AC" {
+		t.Errorf("unexpected final status %+v", status)
+	}
+	if judge.logins != 2 {
+		t.Errorf("expected 2 logins, got %d", judge.logins)
+	}
+}
@@ -0,0 +1,165 @@
+package atcoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mxwell/wac/credentials"
+	"github.com/mxwell/wac/model"
+)
+
+/* Site of the current contest, the legacy one has no submissions and standings in known format */
+func trackedSite(contest *model.Contest) (*contestSite, error) {
+	site, err := parseUrl(contest.Link)
+	if err != nil {
+		return nil, err
+	}
+	if site.legacy {
+		return nil, fmt.Errorf("contests of the legacy site are not supported")
+	}
+	return site, nil
+}
+
+/* Tokens of tasks by their screen names, like "abc123_a" */
+func tokensByScreenName(contest *model.Contest) map[string]string {
+	tokens := make(map[string]string)
+	for token, task := range contest.Tasks {
+		tokens[path.Base(task.Link)] = token
+	}
+	return tokens
+}
+
+/* Values of the submission table, like "15 ms" or "3660 KB" */
+func parseMeasure(s string, unit string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasSuffix(s, unit) {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(s, unit)), 10, 64)
+	return value, err == nil
+}
+
+/* Rows of the table at /submissions/me: time, task, user, language, score, size, status, exec time and memory */
+func parseSubmissions(doc *goquery.Selection, tokens map[string]string) []model.Submission {
+	submissions := make([]model.Submission, 0)
+	doc.Find("table tbody tr").Each(func(i int, s *goquery.Selection) {
+		columns := s.Find("td")
+		id, ok := s.Find("td.submission-score[data-id]").Attr("data-id")
+		if !ok || columns.Length() < 7 {
+			return
+		}
+		submission := model.Submission{
+			Id:       id,
+			Language: strings.TrimSpace(columns.Eq(3).Text()),
+			Verdict:  strings.TrimSpace(columns.Eq(6).Text()),
+		}
+		if at, err := time.Parse("2006-01-02 15:04:05-0700", strings.TrimSpace(columns.Eq(0).Text())); err == nil {
+			submission.SubmittedAt = at
+		}
+		if href, ok := columns.Eq(1).Find("a").Attr("href"); ok {
+			screenName := path.Base(href)
+			if token, ok := tokens[screenName]; ok {
+				submission.Task = token
+			} else {
+				submission.Task = screenName
+			}
+		}
+		/* pending submissions have no time and memory, their status spans the rest of the row */
+		if columns.Length() >= 9 {
+			if millis, ok := parseMeasure(columns.Eq(7).Text(), "ms"); ok {
+				submission.Time = time.Duration(millis) * time.Millisecond
+			}
+			if kilobytes, ok := parseMeasure(columns.Eq(8).Text(), "KB"); ok {
+				submission.Memory = uint64(kilobytes) * 1024
+			}
+		}
+		submissions = append(submissions, submission)
+	})
+	return submissions
+}
+
+func (a AtCoder) GetSubmissions(contest *model.Contest) ([]model.Submission, error) {
+	site, err := trackedSite(contest)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := retrieveDocument(site.base + "/submissions/me")
+	if err != nil {
+		return nil, err
+	}
+	return parseSubmissions(doc, tokensByScreenName(contest)), nil
+}
+
+/* Response of /standings/json, scores are multiplied by 100 */
+type standingsResponse struct {
+	TaskInfo []struct {
+		Assignment     string
+		TaskScreenName string
+	}
+	StandingsData []struct {
+		Rank           int
+		UserScreenName string
+		TotalResult    struct {
+			Score   int64
+			Penalty int
+		}
+		TaskResults map[string]struct {
+			Score int64
+		}
+	}
+}
+
+func parseStandings(response *standingsResponse, leaders int, username string) *model.Standings {
+	result := &model.Standings{Solves: make(map[string]int)}
+	for _, info := range response.TaskInfo {
+		result.Tasks = append(result.Tasks, strings.ToLower(info.Assignment))
+	}
+	for _, data := range response.StandingsData {
+		row := model.StandingsRow{
+			Rank:    data.Rank,
+			Party:   data.UserScreenName,
+			Points:  float64(data.TotalResult.Score) / 100,
+			Penalty: data.TotalResult.Penalty,
+		}
+		for i, info := range response.TaskInfo {
+			if taskResult, ok := data.TaskResults[info.TaskScreenName]; ok && taskResult.Score > 0 {
+				row.Solved = append(row.Solved, result.Tasks[i])
+				result.Solves[result.Tasks[i]]++
+			}
+		}
+		if len(result.Leaders) < leaders {
+			result.Leaders = append(result.Leaders, row)
+		}
+		if result.Mine == nil && strings.EqualFold(data.UserScreenName, username) {
+			mine := row
+			result.Mine = &mine
+		}
+	}
+	return result
+}
+
+func (a AtCoder) GetStandings(contest *model.Contest, leaders int) (*model.Standings, error) {
+	site, err := trackedSite(contest)
+	if err != nil {
+		return nil, err
+	}
+	link := site.base + "/standings/json"
+	body, err := downloadLoggedIn(site, link)
+	if err != nil {
+		return nil, err
+	}
+	var response standingsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("bad standings at %s: %s", link, err)
+	}
+	cred, err := credentials.Get(SessionName, []model.CredentialField{usernameField})
+	if err != nil {
+		return nil, err
+	}
+	return parseStandings(&response, leaders, cred["username"]), nil
+}
@@ -0,0 +1,34 @@
+package atcoder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mxwell/wac/model"
+)
+
+func TestStandingsOfFakeJudge(t *testing.T) {
+	judge := &fakeJudge{t: t}
+	host := useFakeJudge(t, judge)
+	contest := &model.Contest{Link: host + "/contests/abc100"}
+	standings, err := AtCoder{}.GetStandings(contest, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if judge.logins != 1 {
+		t.Errorf("expected 1 login, got %d", judge.logins)
+	}
+	if !reflect.DeepEqual(standings.Tasks, []string{"a", "b"}) {
+		t.Errorf("unexpected tasks %v", standings.Tasks)
+	}
+	if len(standings.Leaders) != 1 || standings.Leaders[0].Party != "<leader>" || standings.Leaders[0].Points != 600 {
+		t.Errorf("unexpected leaders %+v", standings.Leaders)
+	}
+	mine := standings.Mine
+	if mine == nil || mine.Rank != 2 || !reflect.DeepEqual(mine.Solved, []string{"a"}) {
+		t.Errorf("unexpected own row %+v", mine)
+	}
+	if standings.Solves["a"] != 2 || standings.Solves["b"] != 1 {
+		t.Errorf("unexpected solves %v", standings.Solves)
+	}
+}
@@ -0,0 +1,143 @@
+package codeforces
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxwell/wac/credentials"
+	"github.com/mxwell/wac/util"
+)
+
+/* Envelope of every API response, result is decoded by the caller */
+type apiResponse struct {
+	Status  string
+	Comment string
+	Result  json.RawMessage
+}
+
+/* Call API method, like contest.list, and decode its result */
+func callApi(method string, params url.Values, result interface{}) error {
+	client := &http.Client{Transport: util.PlatformTransport}
+	link := CodeforcesHost + "/api/" + method
+	if len(params) > 0 {
+		link += "?" + params.Encode()
+	}
+	resp, err := client.Get(link)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %s", method, err)
+	}
+	defer resp.Body.Close()
+	var response apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("bad response of %s (%s): %s", method, resp.Status, err)
+	}
+	if response.Status != "OK" {
+		return fmt.Errorf("%s failed: %s", method, response.Comment)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+/* Parameters in the order used for signature: sorted by names, then by values */
+func sortedParams(params url.Values) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		values := append([]string(nil), params[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+/* Call API method on behalf of the user, if API key is known, otherwise anonymously */
+func callSignedApi(method string, params url.Values, result interface{}) error {
+	cred, err := credentials.Get(SessionName, apiFields)
+	if err != nil {
+		return err
+	}
+	key, secret := cred["key"], cred["secret"]
+	if len(key) == 0 || len(secret) == 0 {
+		return callApi(method, params, result)
+	}
+	signed := url.Values{}
+	for name, values := range params {
+		signed[name] = values
+	}
+	signed.Set("apiKey", key)
+	signed.Set("time", strconv.FormatInt(time.Now().Unix(), 10))
+	salt := make([]byte, 3)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	prefix := hex.EncodeToString(salt)
+	hash := sha512.Sum512([]byte(prefix + "/" + method + "?" + sortedParams(signed) + "#" + secret))
+	signed.Set("apiSig", prefix+hex.EncodeToString(hash[:]))
+	return callApi(method, signed, result)
+}
+
+type apiContest struct {
+	Id               int
+	Name             string
+	Phase            string
+	StartTimeSeconds int64
+}
+
+/* Outcome of the start time lookup, kept per contest */
+type startTimeResult struct {
+	start time.Time
+	err   error
+}
+
+/* Lookups are done once, so polling for tasks doesn't download contest.list again */
+var startTimeMutex sync.Mutex
+var startTimes = map[string]startTimeResult{}
+
+/* Start time of the contest as announced in contest.list */
+func startTime(ref *contestRef) (time.Time, error) {
+	if ref.group {
+		return time.Time{}, fmt.Errorf("start time unknown: group contests are not listed by API")
+	}
+	startTimeMutex.Lock()
+	defer startTimeMutex.Unlock()
+	result, ok := startTimes[ref.base]
+	if !ok {
+		result.start, result.err = fetchStartTime(ref)
+		startTimes[ref.base] = result
+	}
+	return result.start, result.err
+}
+
+func fetchStartTime(ref *contestRef) (time.Time, error) {
+	var contests []apiContest
+	params := url.Values{"gym": {strconv.FormatBool(ref.gym)}}
+	if err := callApi("contest.list", params, &contests); err != nil {
+		return time.Time{}, err
+	}
+	id, _ := strconv.Atoi(ref.id)
+	for _, contest := range contests {
+		if contest.Id != id {
+			continue
+		}
+		if contest.StartTimeSeconds == 0 {
+			return time.Time{}, fmt.Errorf("start time of contest %d is not announced", id)
+		}
+		return time.Unix(contest.StartTimeSeconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("contest %d is not found in the list of contests", id)
+}
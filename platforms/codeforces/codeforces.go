@@ -3,11 +3,16 @@ package codeforces
 import (
 	"fmt"
 	"log"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+	"github.com/mxwell/wac/credentials"
 	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
 )
 
 type Codeforces struct {
@@ -17,19 +22,50 @@ func InitCodeforces() model.Platform {
 	return Codeforces{}
 }
 
+func (a Codeforces) Name() string {
+	return "codeforces"
+}
+
+var handleField = model.CredentialField{Key: "handle", Prompt: "Codeforces handle"}
+
+/* Credentials required to log in on the site */
+var loginFields = []model.CredentialField{
+	handleField,
+	{Key: "password", Prompt: "Codeforces password", Secret: true},
+}
+
+/* Credentials for authorized API calls, anonymous calls are made without them */
+var apiFields = []model.CredentialField{
+	{Key: "key", Prompt: "Codeforces API key", Optional: true},
+	{Key: "secret", Prompt: "Codeforces API secret", Secret: true, Optional: true},
+}
+
+var credentialFields = append(append([]model.CredentialField(nil), loginFields...), apiFields...)
+
+func (a Codeforces) Credentials() []model.CredentialField {
+	return credentialFields
+}
+
 func (a Codeforces) ValidUrl(url string) bool {
-	_, err := trimUrl(url)
+	_, err := parseUrl(url)
 	return err == nil
 }
 
-const CodeforcesHost = "http://codeforces.com"
+/* Host could be changed with WAC_CODEFORCES_HOST, like to a local fake judge */
+var CodeforcesHost = util.PlatformHost("codeforces", "https://codeforces.com")
+
+/* Specify locale for reproducibility */
+const LocaleQuery = "?locale=en"
 
 func (a Codeforces) GetContest(url string, rootDirName string) (*model.Contest, error) {
-	url, err := trimUrl(url)
+	ref, err := parseUrl(url)
 	if err != nil {
 		return nil, err
 	}
-	doc, err := goquery.NewDocument(url)
+	if len(ref.problem) > 0 {
+		return getSingleProblem(ref, rootDirName)
+	}
+	doc, err := retrieveDocument(ref.base + LocaleQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -70,22 +106,73 @@ func (a Codeforces) GetContest(url string, rootDirName string) (*model.Contest,
 			return
 		}
 		name := strings.TrimSpace(nameElement.Text())
-		tasks[token] = model.Task{CodeforcesHost + href, name, token, make([]string, 0)}
+		tasks[token] = model.Task{Link: CodeforcesHost + href, Name: name, Token: token, TestTokens: make([]string, 0)}
 	})
-	return &model.Contest{url, title, tasks, rootDirName}, nil
+	contest := &model.Contest{Link: ref.base + LocaleQuery, Name: title, Tasks: tasks, RootDir: rootDirName}
+	/* group contests are not listed by API, their start time stays unknown */
+	if !ref.group {
+		if start, err := startTime(ref); err == nil {
+			contest.StartTime = start
+		} else {
+			log.Printf("WARN unable to get start time of the contest: %s\n", err)
+		}
+	}
+	return contest, nil
+}
+
+func (a Codeforces) GetStartTime(url string) (time.Time, error) {
+	ref, err := parseUrl(url)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return startTime(ref)
+}
+
+/* Contest with the only task referenced by problem URL */
+func getSingleProblem(ref *contestRef, rootDirName string) (*model.Contest, error) {
+	link := ref.base + "/problem/" + ref.problem
+	doc, err := retrieveDocument(link + LocaleQuery)
+	if err != nil {
+		return nil, err
+	}
+	titleElement := doc.Find("div.problem-statement div.header div.title")
+	if titleElement.Length() != 1 {
+		return nil, fmt.Errorf("unable to detect problem name")
+	}
+	/* title is like "A. Name" */
+	name := strings.TrimSpace(titleElement.Text())
+	if dot := strings.Index(name, ". "); dot >= 0 {
+		name = name[dot+2:]
+	}
+	title := name
+	if contestElement := doc.Find("#sidebar table.rtable th a"); contestElement.Length() == 1 {
+		title = strings.TrimSpace(contestElement.Text()) + " -- " + name
+	}
+	token := strings.ToLower(ref.problem)
+	tasks := map[string]model.Task{
+		token: model.Task{Link: link, Name: name, Token: token, TestTokens: make([]string, 0)},
+	}
+	return &model.Contest{Link: link + LocaleQuery, Name: title, Tasks: tasks, RootDir: rootDirName}, nil
 }
 
 func (a Codeforces) GetTests(task *model.Task) ([]model.Test, error) {
-	doc, err := goquery.NewDocument(task.Link)
+	doc, err := retrieveDocument(task.Link)
 	if err != nil {
 		return nil, err
 	}
+	task.Interactive = isInteractive(doc)
+	parseProperties(doc, task)
 	sampleTestsElement := doc.Find("div.sample-tests div.sample-test")
 	if sampleTestsElement.Length() != 1 {
+		if task.Interactive {
+			log.Printf("WARN no sample tests for interactive task %s\n", task.Token)
+			return nil, nil
+		}
 		return nil, fmt.Errorf("element with tests is not found")
 	}
 
 	inputs := make(map[int]string)
+	inputGroups := make(map[int][]int)
 	outputs := make(map[int]string)
 	var idOrder []int
 
@@ -96,12 +183,7 @@ func (a Codeforces) GetTests(task *model.Task) ([]model.Test, error) {
 			log.Printf("WARN input is not found in sample %d, ignoring the sample...\n", id)
 			return
 		}
-		if html, err := element.Html(); err == nil {
-			inputs[id] = processHtml(html)
-		} else {
-			log.Printf("WARN unable to retrieve input entry in sample %d, ignoring the sample...\n", id)
-			return
-		}
+		inputs[id], inputGroups[id] = sampleText(element)
 		idOrder = append(idOrder, id)
 	})
 
@@ -112,12 +194,7 @@ func (a Codeforces) GetTests(task *model.Task) ([]model.Test, error) {
 			log.Printf("WARN output is not found in sample %d, ignoring the sample...\n", id)
 			return
 		}
-		if html, err := element.Html(); err == nil {
-			outputs[id] = processHtml(html)
-		} else {
-			log.Printf("WARN unable to retrieve output entry in sample %d, ignoring the sample...\n", id)
-			return
-		}
+		outputs[id], _ = sampleText(element)
 	})
 
 	var result []model.Test
@@ -130,7 +207,7 @@ func (a Codeforces) GetTests(task *model.Task) ([]model.Test, error) {
 			continue
 		}
 		token := fmt.Sprintf("sample%d", id)
-		result = append(result, model.Test{token, input, output})
+		result = append(result, model.Test{Token: token, Input: input, Output: output, InputLineGroups: inputGroups[id]})
 	}
 
 	if len(result) == 0 {
@@ -140,45 +217,263 @@ func (a Codeforces) GetTests(task *model.Task) ([]model.Test, error) {
 	return result, nil
 }
 
-var BrReplacer = strings.NewReplacer("<br/>", "\n")
+/* Formulas are enclosed in $$$, display ones in $$$$$$, unlike the usual $ and $$ */
+var formulaReplacer = strings.NewReplacer("$$$$$$", "$$", "$$$", "$")
 
-func processHtml(html string) string {
-	return BrReplacer.Replace(html)
+func (a Codeforces) GetStatement(task *model.Task) (*model.Statement, error) {
+	doc, err := retrieveDocument(task.Link)
+	if err != nil {
+		return nil, err
+	}
+	statementElement := doc.Find("div.problem-statement").First()
+	if statementElement.Length() == 0 {
+		return nil, fmt.Errorf("statement is not found at %s", task.Link)
+	}
+	content, err := statementElement.Html()
+	if err != nil {
+		return nil, err
+	}
+	return &model.Statement{Html: formulaReplacer.Replace(content), Link: task.Link}, nil
 }
 
-const SchemeHttp = "http://"
-const PathContest = "/contest/"
+/* Interactive problems have a section on interaction instead of plain input and output */
+func isInteractive(doc *goquery.Selection) bool {
+	interactive := false
+	doc.Find("div.problem-statement div.section-title").Each(func(i int, s *goquery.Selection) {
+		title := strings.TrimSpace(s.Text())
+		if title == "Interaction" || title == "Protocol" {
+			interactive = true
+		}
+	})
+	statement := doc.Find("div.problem-statement").Text()
+	return interactive || strings.Contains(statement, "This is an interactive problem")
+}
 
-func trimUrl(url string) (string, error) {
-	var offset int
-	if strings.HasPrefix(url, SchemeHttp) {
-		offset = len(SchemeHttp)
-	} else {
-		return "", fmt.Errorf("no valid scheme is detected in url - %s", url)
+/* Value of problem property, like "2 seconds" in "time limit per test2 seconds" */
+func propertyValue(doc *goquery.Selection, class string) (string, bool) {
+	element := doc.Find("div.problem-statement div.header div." + class).First()
+	if element.Length() == 0 {
+		return "", false
 	}
-	slash := strings.Index(url[offset:], "/")
-	if slash < 0 {
-		return "", fmt.Errorf("url must have path - %s", url)
+	title := element.Find("div.property-title").Text()
+	return strings.TrimSpace(strings.TrimPrefix(element.Text(), title)), true
+}
+
+/* Value like "2 seconds" or "0.5 second" */
+func parseTimeLimit(value string) (time.Duration, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "second") {
+		return 0, fmt.Errorf("unexpected time limit '%s'", value)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected time limit '%s'", value)
 	}
-	slash = offset + slash
-	if !strings.Contains(url[offset:slash], "codeforces.com") {
-		return "", fmt.Errorf("host must be codeforces.com")
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+/* Value like "256 megabytes" */
+func parseMemoryLimit(value string) (uint64, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "megabyte") {
+		return 0, fmt.Errorf("unexpected memory limit '%s'", value)
 	}
-	contest := strings.Index(url[slash:], PathContest)
-	if contest != 0 {
-		return "", fmt.Errorf("path must start with %s", PathContest)
+	megabytes, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected memory limit '%s'", value)
 	}
-	contest = slash + contest
-	offset = contest + len(PathContest)
-	idEnd := strings.IndexAny(url[offset:], "/?")
-	if idEnd >= 0 {
-		idEnd = offset + idEnd
+	return megabytes, nil
+}
+
+/* Limits and names of input/output files from the header of the statement */
+func parseProperties(doc *goquery.Selection, task *model.Task) {
+	if value, ok := propertyValue(doc, "time-limit"); ok {
+		if limit, err := parseTimeLimit(value); err == nil {
+			task.TimeLimit = limit
+		} else {
+			log.Printf("WARN %s\n", err)
+		}
+	}
+	if value, ok := propertyValue(doc, "memory-limit"); ok {
+		if limit, err := parseMemoryLimit(value); err == nil {
+			task.MemoryLimit = limit
+		} else {
+			log.Printf("WARN %s\n", err)
+		}
+	}
+	if value, ok := propertyValue(doc, "input-file"); ok && value != "standard input" && value != "stdin" {
+		task.InputFile = value
+	}
+	if value, ok := propertyValue(doc, "output-file"); ok && value != "standard output" && value != "stdout" {
+		task.OutputFile = value
+	}
+}
+
+/* Lines of samples with several test cases are marked by class test-example-line-N */
+var exampleLineGroupRegexp = regexp.MustCompile(`^test-example-line-([0-9]+)$`)
+
+func exampleLineGroup(line *goquery.Selection) (int, bool) {
+	class, _ := line.Attr("class")
+	for _, name := range strings.Fields(class) {
+		if match := exampleLineGroupRegexp.FindStringSubmatch(name); match != nil {
+			group, err := strconv.Atoi(match[1])
+			return group, err == nil
+		}
+	}
+	return 0, false
+}
+
+/*
+ * Text of the sample as shown in the statement. Older statements separate lines
+ * by <br>, newer ones wrap every line in div.test-example-line. Entities are
+ * decoded by taking text of the nodes. Lines are kept as is, including blank
+ * ones at the end, only the final line break is added if it's missing.
+ * The divs also mark the test case of every line by test-example-line-N, those
+ * are returned along with the text, or nil if lines aren't marked.
+ */
+func sampleText(pre *goquery.Selection) (string, []int) {
+	pre = pre.Clone()
+	pre.Find("br").ReplaceWithHtml("\n")
+	var text string
+	var groups []int
+	if lines := pre.Find("div.test-example-line"); lines.Length() > 0 {
+		var b strings.Builder
+		marked := true
+		lines.Each(func(i int, s *goquery.Selection) {
+			b.WriteString(strings.TrimRight(s.Text(), "\r\n"))
+			b.WriteString("\n")
+			group, ok := exampleLineGroup(s)
+			marked = marked && ok
+			groups = append(groups, group)
+		})
+		text = b.String()
+		if !marked {
+			groups = nil
+		}
 	} else {
-		idEnd = len(url)
+		text = pre.Text()
 	}
-	if _, err := strconv.Atoi(url[offset:idEnd]); err != nil {
-		return "", fmt.Errorf("can't parse contest ID from url")
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	if len(text) > 0 && !strings.HasSuffix(text, "\n") {
+		text += "\n"
 	}
-	/* Specify locale for reproducibility */
-	return url[:idEnd] + "?locale=en", nil
+	return text, groups
+}
+
+/*
+ * Contest is one of /contest/<id>, /gym/<id> or /group/<g>/contest/<id>.
+ * Problem is set when URL points to a single problem of the contest.
+ */
+type contestRef struct {
+	base    string
+	id      string
+	gym     bool
+	group   bool
+	problem string
+}
+
+func isContestId(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func parseUrl(url string) (*contestRef, error) {
+	page, err := util.ParsePageLink(url)
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Path) == 0 {
+		return nil, fmt.Errorf("url must have path - %s", url)
+	}
+	if page.Host != "codeforces.com" && !strings.HasSuffix(page.Host, ".codeforces.com") && page.Host != util.HostOf(CodeforcesHost) {
+		return nil, fmt.Errorf("host must be codeforces.com")
+	}
+	parts := page.Parts()
+	var ref contestRef
+	switch {
+	case len(parts) == 4 && parts[0] == "problemset" && parts[1] == "problem" && isContestId(parts[2]):
+		return &contestRef{base: CodeforcesHost + "/contest/" + parts[2], id: parts[2], problem: parts[3]}, nil
+	case len(parts) >= 2 && (parts[0] == "contest" || parts[0] == "gym") && isContestId(parts[1]):
+		ref.base = CodeforcesHost + "/" + parts[0] + "/" + parts[1]
+		ref.id = parts[1]
+		ref.gym = parts[0] == "gym"
+		parts = parts[2:]
+	case len(parts) >= 4 && parts[0] == "group" && parts[2] == "contest" && isContestId(parts[3]):
+		ref.base = CodeforcesHost + "/group/" + parts[1] + "/contest/" + parts[3]
+		ref.id = parts[3]
+		ref.group = true
+		parts = parts[4:]
+	default:
+		return nil, fmt.Errorf("can't parse contest ID from url")
+	}
+	if len(parts) == 2 && parts[0] == "problem" {
+		ref.problem = parts[1]
+	}
+	return &ref, nil
+}
+
+/* Name of the file with saved cookies */
+const SessionName = "codeforces"
+
+var session = util.NewSession(SessionName)
+
+/* Codeforces redirects to /enter when the page is not available without login */
+func isLoginPage(bow *browser.Browser) bool {
+	return strings.HasPrefix(bow.Url().Path, "/enter")
+}
+
+func login(bow *browser.Browser, cred map[string]string) error {
+	loginLink := CodeforcesHost + "/enter"
+	err := bow.Open(loginLink)
+	if err != nil {
+		return fmt.Errorf("failed to fetch login page - %s: %s", loginLink, err)
+	}
+	/* hidden fields of the form, including CSRF token, are submitted as is */
+	fm, err := bow.Form("form#enterForm")
+	if err != nil {
+		return fmt.Errorf("failed to find login form at %s: %s", loginLink, err)
+	}
+	fm.Input("handleOrEmail", cred["handle"])
+	fm.Input("password", cred["password"])
+	err = fm.Submit()
+	if err != nil {
+		return fmt.Errorf("failed to submit login form at %s: %s", loginLink, err)
+	}
+	if isLoginPage(bow) {
+		return fmt.Errorf("login is rejected, check handle and password")
+	}
+	return nil
+}
+
+/* Open the page, logging in if it isn't available without login */
+func openLoggedIn(bow *browser.Browser, link string) error {
+	return session.OpenLoggedIn(bow, link, isLoginPage, func(bow *browser.Browser) error {
+		cred, err := credentials.Get(SessionName, loginFields)
+		if err != nil {
+			return err
+		}
+		return login(bow, cred)
+	})
+}
+
+/* Fetch the page, logging in first if it is private, like pages of private gyms */
+func retrieveDocument(link string) (*goquery.Selection, error) {
+	bow, err := session.NewBrowser()
+	if err != nil {
+		return nil, err
+	}
+	if err := openLoggedIn(bow, link); err != nil {
+		return nil, err
+	}
+	return bow.Dom(), nil
+}
+
+func (a Codeforces) Login(cred map[string]string) error {
+	return session.Login(func(bow *browser.Browser) error {
+		return login(bow, cred)
+	})
+}
+
+func (a Codeforces) Logout() error {
+	return session.Remove()
 }
@@ -0,0 +1,142 @@
+package codeforces
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseUrl(t *testing.T) {
+	cases := []struct {
+		link string
+		ref  contestRef
+	}{
+		{"https://codeforces.com/contest/1234", contestRef{base: "https://codeforces.com/contest/1234", id: "1234"}},
+		{"http://codeforces.com/contest/1234/", contestRef{base: "https://codeforces.com/contest/1234", id: "1234"}},
+		{"https://codeforces.com/contest/1234/problem/B", contestRef{base: "https://codeforces.com/contest/1234", id: "1234", problem: "B"}},
+		{"https://codeforces.com/contest/1234/problem/C1?locale=en", contestRef{base: "https://codeforces.com/contest/1234", id: "1234", problem: "C1"}},
+		{"https://codeforces.com/contest/1234/standings", contestRef{base: "https://codeforces.com/contest/1234", id: "1234"}},
+		{"https://m1.codeforces.com/contest/1234", contestRef{base: "https://codeforces.com/contest/1234", id: "1234"}},
+		{"https://codeforces.com/problemset/problem/1234/D", contestRef{base: "https://codeforces.com/contest/1234", id: "1234", problem: "D"}},
+		{"https://codeforces.com/gym/100001", contestRef{base: "https://codeforces.com/gym/100001", id: "100001", gym: true}},
+		{"https://codeforces.com/gym/100001/problem/A", contestRef{base: "https://codeforces.com/gym/100001", id: "100001", gym: true, problem: "A"}},
+		{"https://codeforces.com/group/AbCdEf/contest/271828", contestRef{base: "https://codeforces.com/group/AbCdEf/contest/271828", id: "271828", group: true}},
+		{"https://codeforces.com/group/AbCdEf/contest/271828/problem/E", contestRef{base: "https://codeforces.com/group/AbCdEf/contest/271828", id: "271828", group: true, problem: "E"}},
+	}
+	for _, c := range cases {
+		ref, err := parseUrl(c.link)
+		if err != nil {
+			t.Errorf("%s: %s", c.link, err)
+			continue
+		}
+		if *ref != c.ref {
+			t.Errorf("%s: expected %+v, got %+v", c.link, c.ref, *ref)
+		}
+	}
+}
+
+func TestParseUrlErrors(t *testing.T) {
+	for _, link := range []string{
+		"codeforces.com/contest/1234",
+		"https://codeforces.com",
+		"https://codeforces.com/",
+		"https://codeforces.com/contest",
+		"https://codeforces.com/contest/abc",
+		"https://codeforces.com/problemset/problem/1234",
+		"https://codeforces.com/group/AbCdEf",
+		"https://codeforces.com/profile/tourist",
+		"https://atcoder.jp/contest/1234",
+		"https://codeforces.com.example.com/contest/1234",
+	} {
+		if ref, err := parseUrl(link); err == nil {
+			t.Errorf("%s: expected error, got %+v", link, ref)
+		}
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/api/contest.list" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"status":"OK","result":[{"id":1234,"startTimeSeconds":1700000000},{"id":1235}]}`)
+	}))
+	defer server.Close()
+	savedHost := CodeforcesHost
+	defer func() { CodeforcesHost = savedHost }()
+	CodeforcesHost = server.URL
+
+	for i := 0; i < 3; i++ {
+		start, err := startTime(&contestRef{base: server.URL + "/contest/1234", id: "1234"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !start.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("unexpected start time %s", start)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("contest.list is downloaded %d times", calls)
+	}
+	if _, err := startTime(&contestRef{base: server.URL + "/contest/1235", id: "1235"}); err == nil {
+		t.Errorf("start time of contest without one is found")
+	}
+	if _, err := startTime(&contestRef{base: server.URL + "/contest/1", id: "1"}); err == nil {
+		t.Errorf("start time of unknown contest is found")
+	}
+	calls = 0
+	group := &contestRef{base: server.URL + "/group/g/contest/271828", id: "271828", group: true}
+	if _, err := startTime(group); err == nil || calls != 0 {
+		t.Errorf("start time of group contest: %v, %d calls", err, calls)
+	}
+}
+
+func TestSampleText(t *testing.T) {
+	cases := []struct {
+		name   string
+		html   string
+		text   string
+		groups []int
+	}{
+		{"br", "<pre>3<br/>1 2 3<br/></pre>", "3\n1 2 3\n", nil},
+		{"br without final break", "<pre>3<br>1 2 3</pre>", "3\n1 2 3\n", nil},
+		{"plain lines", "<pre>\n3\n1 2 3\n</pre>", "3\n1 2 3\n", nil},
+		{"crlf", "<pre>3\r\n1 2 3\r\n</pre>", "3\n1 2 3\n", nil},
+		{"trailing blank lines", "<pre>3<br/>1 2 3<br/><br/><br/></pre>", "3\n1 2 3\n\n\n", nil},
+		{"inner blank line", "<pre>1<br/><br/>2<br/></pre>", "1\n\n2\n", nil},
+		{"spaces", "<pre>  a  b  <br/></pre>", "  a  b  \n", nil},
+		{"entities", "<pre>a &lt; b &amp;&amp; b &gt; c<br/>&quot;x&quot;<br/></pre>", "a < b && b > c\n\"x\"\n", nil},
+		{"div", `<pre><div class="test-example-line test-example-line-even test-example-line-0">2</div>` +
+			`<div class="test-example-line test-example-line-odd test-example-line-1">1 2</div>` +
+			`<div class="test-example-line test-example-line-odd test-example-line-1">3</div>` +
+			`<div class="test-example-line test-example-line-even test-example-line-2">4 5</div></pre>`, "2\n1 2\n3\n4 5\n", []int{0, 1, 1, 2}},
+		{"div with entities", `<pre><div class="test-example-line">&lt;&gt;&amp;</div><div class="test-example-line">x</div></pre>`, "<>&\nx\n", nil},
+		{"div with blank line", `<pre><div class="test-example-line">1</div><div class="test-example-line"></div>` +
+			`<div class="test-example-line"></div></pre>`, "1\n\n\n", nil},
+		{"empty", "<pre></pre>", "", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(c.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, groups := sampleText(doc.Find("pre"))
+			if text != c.text {
+				t.Errorf("expected %q, got %q", c.text, text)
+			}
+			if !reflect.DeepEqual(groups, c.groups) {
+				t.Errorf("expected groups %v, got %v", c.groups, groups)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package codeforces
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mxwell/wac/credentials"
+	"github.com/mxwell/wac/model"
+)
+
+/* IDs of compilers used for languages of wac config, others are given with --language-id */
+var languageIds = map[string]string{
+	"c++11":   "54",
+	"ocaml":   "19",
+	"python3": "31",
+}
+
+func (a Codeforces) LanguageId(language string) (string, bool) {
+	id, ok := languageIds[language]
+	return id, ok
+}
+
+func (a Codeforces) Submit(task *model.Task, languageId string, source string) (string, error) {
+	ref, err := parseUrl(task.Link)
+	if err != nil {
+		return "", err
+	}
+	if len(ref.problem) == 0 {
+		return "", fmt.Errorf("can't determine problem from task link %s", task.Link)
+	}
+	code, err := ioutil.ReadFile(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read source: %s", err)
+	}
+	bow, err := session.NewBrowser()
+	if err != nil {
+		return "", err
+	}
+	submitLink := ref.base + "/submit"
+	if err := openLoggedIn(bow, submitLink); err != nil {
+		return "", err
+	}
+	/* hidden fields of the form, including CSRF token, are submitted as is */
+	fm, err := bow.Form("form.submit-form")
+	if err != nil {
+		return "", fmt.Errorf("failed to find submit form at %s: %s", submitLink, err)
+	}
+	/* Set, unlike Input, also fills selects which have no option selected */
+	fm.Set("submittedProblemIndex", strings.ToUpper(ref.problem))
+	fm.Set("programTypeId", languageId)
+	fm.Set("source", string(code))
+	if err := fm.Submit(); err != nil {
+		return "", fmt.Errorf("failed to submit form at %s: %s", submitLink, err)
+	}
+	/* the form is shown again with an error, like for a duplicate submission */
+	if strings.HasSuffix(bow.Url().Path, "/submit") {
+		message := strings.TrimSpace(bow.Dom().Find("span.error").First().Text())
+		if len(message) == 0 {
+			message = "unknown reason"
+		}
+		return "", fmt.Errorf("submission is rejected: %s", message)
+	}
+	/* the page with own submissions is shown after submit, the newest is the first */
+	id, ok := bow.Dom().Find("tr[data-submission-id]").First().Attr("data-submission-id")
+	if !ok {
+		return "", fmt.Errorf("submission ID is not found at %s", bow.Url())
+	}
+	return id, nil
+}
+
+type apiSubmission struct {
+	Id              int64
+	Verdict         string
+	PassedTestCount int
+}
+
+func (a Codeforces) GetSubmission(task *model.Task, id string) (*model.SubmissionStatus, error) {
+	ref, err := parseUrl(task.Link)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := credentials.Get(SessionName, []model.CredentialField{handleField})
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{
+		"contestId": {ref.id},
+		"handle":    {cred["handle"]},
+		"from":      {"1"},
+		"count":     {"20"},
+	}
+	var submissions []apiSubmission
+	if err := callApi("contest.status", params, &submissions); err != nil {
+		return nil, err
+	}
+	for _, submission := range submissions {
+		if strconv.FormatInt(submission.Id, 10) != id {
+			continue
+		}
+		status := &model.SubmissionStatus{Id: id, Verdict: submission.Verdict, Tests: submission.PassedTestCount}
+		switch submission.Verdict {
+		case "", "TESTING":
+			status.Verdict = "TESTING"
+		default:
+			status.Final = true
+			status.Accepted = submission.Verdict == "OK"
+			status.CompileError = submission.Verdict == "COMPILATION_ERROR"
+		}
+		return status, nil
+	}
+	return nil, fmt.Errorf("submission %s is not found among recent submissions of %s", id, cred["handle"])
+}
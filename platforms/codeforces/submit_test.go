@@ -0,0 +1,148 @@
+package codeforces
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+)
+
+/* Verdicts and passed tests returned by the fake API on the consecutive polls */
+var fakeVerdicts = []struct {
+	verdict string
+	passed  int
+}{{"", 0}, {"TESTING", 2}, {"TESTING", 5}, {"OK", 12}}
+
+/* Judge with pages and API methods which are used to submit and track a solution */
+type fakeJudge struct {
+	t      *testing.T
+	mutex  sync.Mutex
+	logins int
+	source string
+	polls  int
+}
+
+const fakeSubmissionId = "271828182"
+
+func (j *fakeJudge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	cookie, err := r.Cookie("JSESSIONID")
+	loggedIn := err == nil && cookie.Value == "tourist"
+	switch {
+	case r.URL.Path == "/enter" && r.Method == "GET":
+		fmt.Fprint(w, `<html><body><form method="post" action="" id="enterForm">
+<input type="hidden" name="csrf_token" value="token"><input type="hidden" name="action" value="enter">
+<input type="text" name="handleOrEmail"><input type="password" name="password">
+<input type="submit" value="Login"></form></body></html>`)
+	case r.URL.Path == "/enter":
+		if r.FormValue("csrf_token") != "token" || r.FormValue("handleOrEmail") != "tourist" || r.FormValue("password") != "secret" {
+			http.Redirect(w, r, "/enter", http.StatusFound)
+			return
+		}
+		j.logins++
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "tourist", Path: "/"})
+		http.Redirect(w, r, "/", http.StatusFound)
+	case r.URL.Path == "/":
+		fmt.Fprint(w, `<html><body>Codeforces</body></html>`)
+	case !loggedIn && !strings.HasPrefix(r.URL.Path, "/api/"):
+		http.Redirect(w, r, "/enter?back="+r.URL.Path, http.StatusFound)
+	case r.URL.Path == "/contest/1234/submit" && r.Method == "GET":
+		fmt.Fprint(w, `<html><body><form class="submit-form" method="post" action="/contest/1234/submit?csrf_token=token">
+<input type="hidden" name="csrf_token" value="token"><input type="hidden" name="action" value="submitSolutionFormSubmitted">
+<select name="submittedProblemIndex"><option value="">Choose problem</option><option value="A">A - Sum</option><option value="B">B - Product</option></select>
+<select name="programTypeId"><option value="54">GNU G++17 7.3.0</option><option value="31">Python 3.8.10</option></select>
+<textarea name="source"></textarea>
+<input type="submit" value="Submit"></form></body></html>`)
+	case r.URL.Path == "/contest/1234/submit":
+		if r.FormValue("csrf_token") != "token" || r.FormValue("submittedProblemIndex") != "B" || r.FormValue("programTypeId") != "31" {
+			j.t.Errorf("bad submission form: %v", r.PostForm)
+			http.Redirect(w, r, "/contest/1234/submit", http.StatusFound)
+			return
+		}
+		j.source = r.FormValue("source")
+		http.Redirect(w, r, "/contest/1234/my", http.StatusFound)
+	case r.URL.Path == "/contest/1234/my":
+		fmt.Fprintf(w, `<html><body><table class="status-frame-datatable">
+<tr><th>#</th><th>When</th></tr>
+<tr data-submission-id="%s"><td>%s</td><td>Oct/17/2026 15:00</td></tr>
+</table></body></html>`, fakeSubmissionId, fakeSubmissionId)
+	case r.URL.Path == "/api/contest.status":
+		query := r.URL.Query()
+		if query.Get("contestId") != "1234" || query.Get("handle") != "tourist" {
+			j.t.Errorf("bad contest.status query: %v", query)
+		}
+		verdict := fakeVerdicts[j.polls]
+		if j.polls+1 < len(fakeVerdicts) {
+			j.polls++
+		}
+		fmt.Fprintf(w, `{"status":"OK","result":[{"id":%s,"verdict":"%s","passedTestCount":%d},{"id":1,"verdict":"WRONG_ANSWER"}]}`,
+			fakeSubmissionId, verdict.verdict, verdict.passed)
+	case r.URL.Path == "/api/contest.standings":
+		query := r.URL.Query()
+		rows := fakeLeaders
+		if query.Get("handles") == "tourist" {
+			rows = fakeOwnRow
+		} else if query.Get("from") != "1" || query.Get("count") != "1" {
+			j.t.Errorf("whole ranklist is requested: %v", query)
+		}
+		fmt.Fprintf(w, `{"status":"OK","result":{"problems":[{"index":"A"},{"index":"B"}],"rows":[%s]}}`, rows)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+/* Platform host, session and credentials are pointed to the fake judge and a temporary home */
+func useFakeJudge(t *testing.T, judge http.Handler) string {
+	server := httptest.NewServer(judge)
+	t.Cleanup(server.Close)
+	savedHost, savedSession, savedInterval := CodeforcesHost, session, util.HostInterval
+	t.Cleanup(func() { CodeforcesHost, session, util.HostInterval = savedHost, savedSession, savedInterval })
+	CodeforcesHost = server.URL
+	session = util.NewSession(SessionName)
+	util.HostInterval = 0
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("WAC_CODEFORCES_HANDLE", "tourist")
+	t.Setenv("WAC_CODEFORCES_PASSWORD", "secret")
+	return server.URL
+}
+
+func TestSubmitToFakeJudge(t *testing.T) {
+	judge := &fakeJudge{t: t}
+	host := useFakeJudge(t, judge)
+	source := filepath.Join(t.TempDir(), "main.py")
+	if err := ioutil.WriteFile(source, []byte("print(42)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	task := &model.Task{Link: host + "/contest/1234/problem/B", Token: "b"}
+	id, err := Codeforces{}.Submit(task, "31", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != fakeSubmissionId {
+		t.Errorf("expected submission %s, got %s", fakeSubmissionId, id)
+	}
+	if judge.source != "print(42)\n" || judge.logins != 1 {
+		t.Errorf("source %q is submitted after %d login(s)", judge.source, judge.logins)
+	}
+	var status *model.SubmissionStatus
+	for i := 0; i < 2*len(fakeVerdicts) && (status == nil || !status.Final); i++ {
+		status, err = Codeforces{}.GetSubmission(task, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && (status.Final || status.Tests != 2) {
+			t.Errorf("progress is not recognized: %+v", status)
+		}
+	}
+	if !status.Final || !status.Accepted || status.Tests != 12 {
+		t.Errorf("unexpected final status %+v", status)
+	}
+}
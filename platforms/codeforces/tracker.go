@@ -0,0 +1,196 @@
+package codeforces
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mxwell/wac/credentials"
+	"github.com/mxwell/wac/model"
+)
+
+type apiProblem struct {
+	Index string
+}
+
+type apiContestSubmission struct {
+	Id                  int64
+	CreationTimeSeconds int64
+	Problem             apiProblem
+	ProgrammingLanguage string
+	Verdict             string
+	TimeConsumedMillis  int64
+	MemoryConsumedBytes uint64
+}
+
+type apiParty struct {
+	TeamName string
+	Members  []struct {
+		Handle string
+	}
+}
+
+type apiRanklistRow struct {
+	Party          apiParty
+	Rank           int
+	Points         float64
+	Penalty        int
+	ProblemResults []struct {
+		Points float64
+	}
+}
+
+type apiStandings struct {
+	Problems []apiProblem
+	Rows     []apiRanklistRow
+}
+
+func taskToken(problem apiProblem) string {
+	return strings.ToLower(problem.Index)
+}
+
+func (p *apiParty) name() string {
+	if len(p.TeamName) > 0 {
+		return p.TeamName
+	}
+	handles := make([]string, 0, len(p.Members))
+	for _, member := range p.Members {
+		handles = append(handles, member.Handle)
+	}
+	return strings.Join(handles, ", ")
+}
+
+func (p *apiParty) hasMember(handle string) bool {
+	for _, member := range p.Members {
+		if strings.EqualFold(member.Handle, handle) {
+			return true
+		}
+	}
+	return false
+}
+
+func userHandle() (string, error) {
+	cred, err := credentials.Get(SessionName, []model.CredentialField{handleField})
+	if err != nil {
+		return "", err
+	}
+	return cred["handle"], nil
+}
+
+func (a Codeforces) GetSubmissions(contest *model.Contest) ([]model.Submission, error) {
+	ref, err := parseUrl(contest.Link)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := userHandle()
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{
+		"contestId": {ref.id},
+		"handle":    {handle},
+	}
+	var submissions []apiContestSubmission
+	if err := callSignedApi("contest.status", params, &submissions); err != nil {
+		return nil, err
+	}
+	result := make([]model.Submission, 0, len(submissions))
+	for _, submission := range submissions {
+		verdict := submission.Verdict
+		if len(verdict) == 0 {
+			verdict = "TESTING"
+		}
+		result = append(result, model.Submission{
+			Id:          strconv.FormatInt(submission.Id, 10),
+			Task:        taskToken(submission.Problem),
+			Language:    submission.ProgrammingLanguage,
+			Verdict:     verdict,
+			Time:        time.Duration(submission.TimeConsumedMillis) * time.Millisecond,
+			Memory:      submission.MemoryConsumedBytes,
+			SubmittedAt: time.Unix(submission.CreationTimeSeconds, 0),
+		})
+	}
+	return result, nil
+}
+
+/* Row of the ranklist with tokens of tasks solved by the party */
+func standingsRow(row *apiRanklistRow, tasks []string) model.StandingsRow {
+	converted := model.StandingsRow{
+		Rank:    row.Rank,
+		Party:   row.Party.name(),
+		Points:  row.Points,
+		Penalty: row.Penalty,
+	}
+	for i, problemResult := range row.ProblemResults {
+		if problemResult.Points > 0 && i < len(tasks) {
+			converted.Solved = append(converted.Solved, tasks[i])
+		}
+	}
+	return converted
+}
+
+/*
+ * Only the leaders and the row of the user are requested, as the whole ranklist of
+ * a big round is megabytes. Solve counts are unknown then.
+ */
+func (a Codeforces) GetStandings(contest *model.Contest, leaders int) (*model.Standings, error) {
+	ref, err := parseUrl(contest.Link)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := userHandle()
+	if err != nil {
+		return nil, err
+	}
+	count := leaders
+	if count < 1 {
+		/* problems of the contest are still needed */
+		count = 1
+	}
+	params := url.Values{
+		"contestId":      {ref.id},
+		"showUnofficial": {"false"},
+		"from":           {"1"},
+		"count":          {strconv.Itoa(count)},
+	}
+	var standings apiStandings
+	if err := callSignedApi("contest.standings", params, &standings); err != nil {
+		return nil, err
+	}
+	result := &model.Standings{}
+	for _, problem := range standings.Problems {
+		result.Tasks = append(result.Tasks, taskToken(problem))
+	}
+	for i := range standings.Rows {
+		row := &standings.Rows[i]
+		converted := standingsRow(row, result.Tasks)
+		if len(result.Leaders) < leaders {
+			result.Leaders = append(result.Leaders, converted)
+		}
+		if result.Mine == nil && row.Party.hasMember(handle) {
+			mine := converted
+			result.Mine = &mine
+		}
+	}
+	if result.Mine != nil {
+		return result, nil
+	}
+	params = url.Values{
+		"contestId":      {ref.id},
+		"showUnofficial": {"false"},
+		"handles":        {handle},
+	}
+	var own apiStandings
+	if err := callSignedApi("contest.standings", params, &own); err != nil {
+		return nil, err
+	}
+	for i := range own.Rows {
+		if own.Rows[i].Party.hasMember(handle) {
+			mine := standingsRow(&own.Rows[i], result.Tasks)
+			result.Mine = &mine
+			break
+		}
+	}
+	return result, nil
+}
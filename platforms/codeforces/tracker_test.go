@@ -0,0 +1,34 @@
+package codeforces
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mxwell/wac/model"
+)
+
+const fakeLeaders = `{"party":{"members":[{"handle":"petr"}]},"rank":1,"points":2,"penalty":10,"problemResults":[{"points":1},{"points":1}]}`
+
+const fakeOwnRow = `{"party":{"members":[{"handle":"tourist"}]},"rank":57,"points":1,"penalty":3,"problemResults":[{"points":0},{"points":1}]}`
+
+func TestStandingsOfFakeJudge(t *testing.T) {
+	host := useFakeJudge(t, &fakeJudge{t: t})
+	contest := &model.Contest{Link: host + "/contest/1234"}
+	standings, err := Codeforces{}.GetStandings(contest, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(standings.Tasks, []string{"a", "b"}) {
+		t.Errorf("unexpected tasks %v", standings.Tasks)
+	}
+	if len(standings.Leaders) != 1 || standings.Leaders[0].Party != "petr" || standings.Leaders[0].Rank != 1 {
+		t.Errorf("unexpected leaders %+v", standings.Leaders)
+	}
+	mine := standings.Mine
+	if mine == nil || mine.Rank != 57 || !reflect.DeepEqual(mine.Solved, []string{"b"}) {
+		t.Errorf("unexpected own row %+v", mine)
+	}
+	if standings.Solves != nil {
+		t.Errorf("solves are counted over a part of the ranklist: %v", standings.Solves)
+	}
+}
@@ -0,0 +1,450 @@
+package cses
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+	"github.com/mxwell/wac/credentials"
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+)
+
+type Cses struct {
+}
+
+func InitCses() model.Platform {
+	return Cses{}
+}
+
+func (a Cses) Name() string {
+	return "cses"
+}
+
+/* Credentials are needed only for hidden tests */
+var credentialFields = []model.CredentialField{
+	{Key: "username", Prompt: "CSES user name"},
+	{Key: "password", Prompt: "CSES password", Secret: true},
+}
+
+func (a Cses) Credentials() []model.CredentialField {
+	return credentialFields
+}
+
+func (a Cses) ValidUrl(link string) bool {
+	_, err := parseUrl(link)
+	return err == nil
+}
+
+/* Host could be changed with WAC_CSES_HOST, like to a local fake judge */
+var CsesHost = util.PlatformHost("cses", "https://cses.fi")
+
+/* Shorthand for a section of the problem set, like "cses:Dynamic Programming" */
+const SectionPrefix = "cses:"
+
+/* Group of tests downloaded from the archive of a solved task */
+const HiddenGroup = "hidden"
+
+/*
+ * Problem set is /problemset/ or /problemset/list/, a section of it is given
+ * in the fragment, like /problemset/#dynamic-programming, or by shorthand.
+ * Task is /problemset/task/<id>.
+ */
+type problemSetRef struct {
+	section string
+	task    string
+}
+
+func parseUrl(link string) (*problemSetRef, error) {
+	if strings.HasPrefix(link, SectionPrefix) {
+		section := strings.TrimSpace(link[len(SectionPrefix):])
+		if len(section) == 0 {
+			return nil, fmt.Errorf("section name is empty")
+		}
+		return &problemSetRef{section: section}, nil
+	}
+	page, err := util.ParsePageLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if page.Host != "cses.fi" && page.Host != "www.cses.fi" && page.Host != util.HostOf(CsesHost) {
+		return nil, fmt.Errorf("bad CSES URL")
+	}
+	ref := &problemSetRef{section: page.Fragment}
+	parts := page.Parts()
+	if len(parts) == 0 || parts[0] != "problemset" {
+		return nil, fmt.Errorf("bad CSES URL")
+	}
+	switch {
+	case len(parts) == 1 || (len(parts) == 2 && parts[1] == "list"):
+		return ref, nil
+	case len(parts) == 3 && parts[1] == "task" && len(parts[2]) > 0:
+		ref.section = ""
+		ref.task = parts[2]
+		return ref, nil
+	}
+	return nil, fmt.Errorf("bad CSES URL")
+}
+
+func taskLink(id string) string {
+	return CsesHost + "/problemset/task/" + id
+}
+
+/* Tasks are too many for letters, so tokens are made of their names, or of their ids if names give nothing */
+func taskToken(name string, id string) string {
+	if token := util.Slug(name); len(token) > 0 {
+		return token
+	}
+	return id
+}
+
+func (a Cses) GetContest(link string, rootDirName string) (*model.Contest, error) {
+	ref, err := parseUrl(link)
+	if err != nil {
+		return nil, err
+	}
+	if len(ref.task) > 0 {
+		return getSingleTask(ref, rootDirName)
+	}
+	listLink := CsesHost + "/problemset/"
+	doc, err := retrieveDocument(listLink)
+	if err != nil {
+		return nil, err
+	}
+	sections := parseSections(doc)
+	name := "CSES Problem Set"
+	tasks := make(map[string]model.Task)
+	if len(ref.section) > 0 {
+		section, ok := findSection(sections, ref.section)
+		if !ok {
+			return nil, fmt.Errorf("section '%s' is not found, known sections: %s", ref.section, strings.Join(sectionNames(sections), ", "))
+		}
+		name += " -- " + section.name
+		addTasks(tasks, section.tasks)
+	} else {
+		for _, section := range sections {
+			addTasks(tasks, section.tasks)
+		}
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no tasks are found at %s", listLink)
+	}
+	contestLink := listLink
+	if len(ref.section) > 0 {
+		contestLink += "#" + util.Slug(ref.section)
+	}
+	return &model.Contest{Link: contestLink, Name: name, Tasks: tasks, RootDir: rootDirName}, nil
+}
+
+type section struct {
+	name  string
+	tasks []model.Task
+}
+
+/* Sections are headings, each followed by the list of its tasks */
+func parseSections(doc *goquery.Selection) []section {
+	var sections []section
+	doc.Find("h2").Each(func(i int, s *goquery.Selection) {
+		current := section{name: strings.TrimSpace(s.Text())}
+		s.NextFiltered("ul.task-list").Find("li.task a").Each(func(j int, a *goquery.Selection) {
+			href, ok := a.Attr("href")
+			if !ok || !strings.Contains(href, "/problemset/task/") {
+				return
+			}
+			name := strings.TrimSpace(a.Text())
+			id := path.Base(href)
+			current.tasks = append(current.tasks, model.Task{
+				Link:       taskLink(id),
+				Name:       name,
+				Token:      taskToken(name, id),
+				TestTokens: make([]string, 0),
+			})
+		})
+		if len(current.tasks) > 0 {
+			sections = append(sections, current)
+		}
+	})
+	return sections
+}
+
+/* Section matches by name, ignoring case and punctuation, so "dynamic-programming" works too */
+func findSection(sections []section, name string) (*section, bool) {
+	for i := range sections {
+		if util.Slug(sections[i].name) == util.Slug(name) {
+			return &sections[i], true
+		}
+	}
+	return nil, false
+}
+
+func sectionNames(sections []section) []string {
+	var names []string
+	for _, s := range sections {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func addTasks(tasks map[string]model.Task, list []model.Task) {
+	for _, task := range list {
+		if _, ok := tasks[task.Token]; ok {
+			log.Printf("WARN duplicate token %s, task %s is skipped\n", task.Token, task.Link)
+			continue
+		}
+		tasks[task.Token] = task
+	}
+}
+
+/* Contest with the only task referenced by task URL */
+func getSingleTask(ref *problemSetRef, rootDirName string) (*model.Contest, error) {
+	link := taskLink(ref.task)
+	doc, err := retrieveDocument(link)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(doc.Find("div.title-block h1").First().Text())
+	if len(name) == 0 {
+		return nil, fmt.Errorf("unable to detect task name")
+	}
+	token := taskToken(name, ref.task)
+	task := model.Task{Link: link, Name: name, Token: token, TestTokens: make([]string, 0)}
+	parseLimits(doc, &task)
+	tasks := map[string]model.Task{token: task}
+	return &model.Contest{Link: link, Name: name, Tasks: tasks, RootDir: rootDirName}, nil
+}
+
+/* The problem set is always open */
+func (a Cses) GetStartTime(link string) (time.Time, error) {
+	if _, err := parseUrl(link); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("CSES problem set has no start time")
+}
+
+var timeLimitRegexp = regexp.MustCompile(`Time limit:\s*([0-9.]+)\s*s`)
+var memoryLimitRegexp = regexp.MustCompile(`Memory limit:\s*([0-9]+)\s*MB`)
+
+/* Limits are listed above the statement, like "Time limit: 1.00 s" and "Memory limit: 512 MB" */
+func parseLimits(doc *goquery.Selection, task *model.Task) {
+	text := doc.Find("ul.task-constraints").Text()
+	if match := timeLimitRegexp.FindStringSubmatch(text); match != nil {
+		if seconds, err := strconv.ParseFloat(match[1], 64); err == nil {
+			task.TimeLimit = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if match := memoryLimitRegexp.FindStringSubmatch(text); match != nil {
+		if megabytes, err := strconv.ParseUint(match[1], 10, 64); err == nil {
+			task.MemoryLimit = megabytes
+		}
+	}
+}
+
+/* Statements of interactive tasks say so explicitly */
+func isInteractive(statement *goquery.Selection) bool {
+	return strings.Contains(strings.ToLower(statement.Text()), "this is an interactive problem")
+}
+
+func withNewline(s string) string {
+	if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
+
+/* Examples are blocks preceded by "Input:" and "Output:" paragraphs */
+func parseSamples(statement *goquery.Selection) []model.Test {
+	var result []model.Test
+	expected, input := "", ""
+	statement.Children().Each(func(i int, s *goquery.Selection) {
+		switch goquery.NodeName(s) {
+		case "p":
+			text := strings.TrimSpace(s.Text())
+			switch {
+			case strings.HasPrefix(text, "Input"):
+				expected = "input"
+			case strings.HasPrefix(text, "Output"):
+				expected = "output"
+			default:
+				expected = ""
+			}
+		case "pre":
+			switch expected {
+			case "input":
+				input = withNewline(s.Text())
+			case "output":
+				token := fmt.Sprintf("sample%d", len(result)+1)
+				result = append(result, model.Test{Token: token, Input: input, Output: withNewline(s.Text())})
+			}
+			expected = ""
+		}
+	})
+	return result
+}
+
+func (a Cses) GetTests(task *model.Task) ([]model.Test, error) {
+	doc, err := retrieveDocument(task.Link)
+	if err != nil {
+		return nil, err
+	}
+	parseLimits(doc, task)
+	statement := doc.Find("div.content div.md").First()
+	task.Interactive = isInteractive(statement)
+	tests := parseSamples(statement)
+	if len(tests) == 0 {
+		if task.Interactive {
+			log.Printf("WARN no sample tests for interactive task %s\n", task.Token)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no valid sample tests were found")
+	}
+	return tests, nil
+}
+
+func (a Cses) GetStatement(task *model.Task) (*model.Statement, error) {
+	doc, err := retrieveDocument(task.Link)
+	if err != nil {
+		return nil, err
+	}
+	statementElement := doc.Find("div.content div.md").First()
+	if statementElement.Length() == 0 {
+		return nil, fmt.Errorf("statement is not found at %s", task.Link)
+	}
+	content, err := statementElement.Html()
+	if err != nil {
+		return nil, err
+	}
+	return &model.Statement{Html: content, Link: task.Link}, nil
+}
+
+/* Archive holds pairs of files, like "1.in" and "1.out" */
+func parseArchive(archive []byte) ([]model.Test, error) {
+	archived, err := util.ReadTestArchive(archive)
+	if err != nil {
+		return nil, fmt.Errorf("bad archive of tests: %s", err)
+	}
+	var result []model.Test
+	for _, test := range archived {
+		result = append(result, model.Test{Token: HiddenGroup + test.Name, Input: test.Input, Output: test.Output, Group: HiddenGroup})
+	}
+	return result, nil
+}
+
+/* Fields of the form as the browser submits them, including the button */
+func formValues(form *goquery.Selection) url.Values {
+	values := make(url.Values)
+	form.Find("input[name]").Each(func(i int, s *goquery.Selection) {
+		inputType := strings.ToLower(s.AttrOr("type", ""))
+		if _, checked := s.Attr("checked"); (inputType == "checkbox" || inputType == "radio") && !checked {
+			return
+		}
+		values.Add(s.AttrOr("name", ""), s.AttrOr("value", ""))
+	})
+	return values
+}
+
+/* Tests are given out to users who have solved the task, the archive is downloaded by a form */
+func (a Cses) GetTestArchive(task *model.Task) ([]model.Test, error) {
+	testsLink := CsesHost + "/problemset/tests/" + path.Base(task.Link) + "/"
+	bow, err := session.NewBrowser()
+	if err != nil {
+		return nil, err
+	}
+	if err := openLoggedIn(bow, testsLink); err != nil {
+		return nil, err
+	}
+	form := bow.Dom().Find(`form:has(input[name="download"])`).First()
+	if form.Length() == 0 {
+		return nil, fmt.Errorf("tests aren't available at %s, the task should be solved first", testsLink)
+	}
+	/* the archive isn't a page, so the form is posted past the browser */
+	action, err := url.Parse(form.AttrOr("action", ""))
+	if err != nil {
+		return nil, fmt.Errorf("bad download form at %s: %s", testsLink, err)
+	}
+	archive, _, err := session.PostForm(bow.ResolveUrl(action).String(), formValues(form))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tests from %s: %s", testsLink, err)
+	}
+	tests, err := parseArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("no tests in archive of %s", testsLink)
+	}
+	return tests, nil
+}
+
+/* Name of the file with saved cookies */
+const SessionName = "cses"
+
+var session = util.NewSession(SessionName)
+
+/* Pages are public, but the link to log out is shown only to users who are logged in */
+func isLoggedIn(bow *browser.Browser) bool {
+	return bow.Dom().Find(`a[href="/logout"]`).Length() > 0
+}
+
+func login(bow *browser.Browser, cred map[string]string) error {
+	loginLink := CsesHost + "/login"
+	err := bow.Open(loginLink)
+	if err != nil {
+		return fmt.Errorf("failed to fetch login page - %s: %s", loginLink, err)
+	}
+	/* hidden fields of the form, including CSRF token, are submitted as is */
+	fm, err := bow.Form(`form:has(input[name="pass"])`)
+	if err != nil {
+		return fmt.Errorf("failed to find login form at %s: %s", loginLink, err)
+	}
+	fm.Input("nick", cred["username"])
+	fm.Input("pass", cred["password"])
+	err = fm.Submit()
+	if err != nil {
+		return fmt.Errorf("failed to submit login form at %s: %s", loginLink, err)
+	}
+	if !isLoggedIn(bow) {
+		return fmt.Errorf("login is rejected, check user name and password")
+	}
+	return nil
+}
+
+/* Open the page, logging in first if there is no valid session */
+func openLoggedIn(bow *browser.Browser, link string) error {
+	denied := func(bow *browser.Browser) bool { return !isLoggedIn(bow) }
+	return session.OpenLoggedIn(bow, link, denied, func(bow *browser.Browser) error {
+		cred, err := credentials.Get(SessionName, credentialFields)
+		if err != nil {
+			return err
+		}
+		return login(bow, cred)
+	})
+}
+
+/* Public pages are fetched with the session, if any, but without logging in */
+func retrieveDocument(link string) (*goquery.Selection, error) {
+	bow, err := session.NewBrowser()
+	if err != nil {
+		return nil, err
+	}
+	if err := bow.Open(link); err != nil {
+		return nil, fmt.Errorf("failed to fetch requested page - %s: %s", link, err)
+	}
+	return bow.Dom(), nil
+}
+
+func (a Cses) Login(cred map[string]string) error {
+	return session.Login(func(bow *browser.Browser) error {
+		return login(bow, cred)
+	})
+}
+
+func (a Cses) Logout() error {
+	return session.Remove()
+}
@@ -0,0 +1,185 @@
+package cses
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+)
+
+func TestParseUrl(t *testing.T) {
+	cases := []struct {
+		link string
+		ref  problemSetRef
+	}{
+		{"https://cses.fi/problemset/", problemSetRef{}},
+		{"https://cses.fi/problemset", problemSetRef{}},
+		{"https://cses.fi/problemset/list/", problemSetRef{}},
+		{"https://cses.fi/problemset/#dynamic-programming", problemSetRef{section: "dynamic-programming"}},
+		{"cses:Dynamic Programming", problemSetRef{section: "Dynamic Programming"}},
+		{"cses: Graph Algorithms ", problemSetRef{section: "Graph Algorithms"}},
+		{"https://cses.fi/problemset/task/1068", problemSetRef{task: "1068"}},
+		{"https://cses.fi/problemset/task/1068/#top", problemSetRef{task: "1068"}},
+		{"http://www.cses.fi/problemset/task/1068?lang=en", problemSetRef{task: "1068"}},
+	}
+	for _, c := range cases {
+		ref, err := parseUrl(c.link)
+		if err != nil {
+			t.Errorf("%s: %s", c.link, err)
+			continue
+		}
+		if *ref != c.ref {
+			t.Errorf("%s: expected %+v, got %+v", c.link, c.ref, *ref)
+		}
+	}
+}
+
+func TestParseUrlErrors(t *testing.T) {
+	for _, link := range []string{
+		"cses:",
+		"cses.fi/problemset/",
+		"https://cses.fi/",
+		"https://cses.fi/problemset/task/",
+		"https://cses.fi/problemset/stats/1068/",
+		"https://cses.fi/book/",
+		"https://codeforces.com/problemset/",
+	} {
+		if ref, err := parseUrl(link); err == nil {
+			t.Errorf("%s: expected error, got %+v", link, ref)
+		}
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	html := `<h2>Introductory Problems</h2><ul class="task-list">
+<li class="task"><a href="/problemset/task/1068">Weird Algorithm</a></li>
+<li class="task"><a href="/problemset/task/1083">Missing Number</a></li></ul>
+<h2>Other</h2><ul class="task-list"><li class="task"><a href="/problemset/task/2000">Задача</a></li></ul>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := parseSections(doc.Selection)
+	if len(sections) != 2 || sections[0].name != "Introductory Problems" || len(sections[0].tasks) != 2 {
+		t.Fatalf("unexpected sections %+v", sections)
+	}
+	var tokens []string
+	for _, s := range sections {
+		for _, task := range s.tasks {
+			tokens = append(tokens, task.Token)
+		}
+	}
+	if expected := []string{"weird-algorithm", "missing-number", "2000"}; strings.Join(tokens, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected tokens %v, got %v", expected, tokens)
+	}
+}
+
+func checkArchivedTests(t *testing.T, tests []model.Test) {
+	t.Helper()
+	expected := []model.Test{
+		{Token: "hidden1", Input: "3\n1 2 3\n", Output: "6\n", Group: HiddenGroup},
+		{Token: "hidden2", Input: "1\n-5\n", Output: "-5\n", Group: HiddenGroup},
+		{Token: "hidden10", Input: "2\n1000000000 1000000000\n", Output: "2000000000\n", Group: HiddenGroup},
+	}
+	if len(tests) != len(expected) {
+		t.Fatalf("expected %d tests, got %+v", len(expected), tests)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(tests[i], expected[i]) {
+			t.Errorf("expected %+v, got %+v", expected[i], tests[i])
+		}
+	}
+}
+
+func TestParseArchive(t *testing.T) {
+	archive, err := ioutil.ReadFile("testdata/tests.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests, err := parseArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkArchivedTests(t, tests)
+	if _, err := parseArchive([]byte("<html>not an archive</html>")); err == nil {
+		t.Errorf("page is taken for archive")
+	}
+}
+
+/* Site giving out tests of task 1068 to the user who is logged in */
+type fakeSite struct {
+	archive []byte
+	logins  int
+}
+
+func (s *fakeSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("PHPSESSID")
+	loggedIn := err == nil && cookie.Value == "tourist"
+	menu := `<a href="/login">Login</a>`
+	if loggedIn {
+		menu = `<a href="/logout">Log out</a>`
+	}
+	switch {
+	case r.URL.Path == "/login" && r.Method == "GET":
+		fmt.Fprintf(w, `<html><body>%s<form method="post">
+<input type="hidden" name="csrf_token" value="token">
+<input type="text" name="nick"><input type="password" name="pass">
+<input type="submit" value="Submit"></form></body></html>`, menu)
+	case r.URL.Path == "/login":
+		if r.FormValue("csrf_token") != "token" || r.FormValue("nick") != "tourist" || r.FormValue("pass") != "secret" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		s.logins++
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "tourist", Path: "/"})
+		http.Redirect(w, r, "/", http.StatusFound)
+	case r.URL.Path == "/":
+		fmt.Fprintf(w, `<html><body>%s</body></html>`, menu)
+	case r.URL.Path == "/problemset/tests/1068/" && r.Method == "GET":
+		fmt.Fprintf(w, `<html><body>%s`, menu)
+		if loggedIn {
+			fmt.Fprint(w, `<form method="post"><input type="hidden" name="csrf_token" value="token">
+<input type="submit" name="download" value="Download"></form>`)
+		}
+		fmt.Fprint(w, `</body></html>`)
+	case r.URL.Path == "/problemset/tests/1068/" && loggedIn && r.FormValue("csrf_token") == "token" && r.FormValue("download") == "Download":
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(s.archive)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGetTestArchive(t *testing.T) {
+	archive, err := ioutil.ReadFile("testdata/tests.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := &fakeSite{archive: archive}
+	server := httptest.NewServer(site)
+	defer server.Close()
+	savedHost, savedSession, savedInterval := CsesHost, session, util.HostInterval
+	defer func() { CsesHost, session, util.HostInterval = savedHost, savedSession, savedInterval }()
+	CsesHost = server.URL
+	session = util.NewSession(SessionName)
+	util.HostInterval = 0
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("WAC_CSES_USERNAME", "tourist")
+	t.Setenv("WAC_CSES_PASSWORD", "secret")
+
+	tests, err := Cses{}.GetTestArchive(&model.Task{Link: taskLink("1068"), Token: "weird-algorithm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkArchivedTests(t, tests)
+	if site.logins != 1 {
+		t.Errorf("expected 1 login, got %d", site.logins)
+	}
+}
@@ -0,0 +1,286 @@
+package kattis
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mxwell/wac/model"
+	"github.com/mxwell/wac/util"
+)
+
+type Kattis struct {
+}
+
+func InitKattis() model.Platform {
+	return Kattis{}
+}
+
+func (a Kattis) Name() string {
+	return "kattis"
+}
+
+/* Problems and samples are public, no credentials are needed */
+func (a Kattis) Credentials() []model.CredentialField {
+	return nil
+}
+
+func (a Kattis) ValidUrl(link string) bool {
+	_, err := parseUrl(link)
+	return err == nil
+}
+
+/* Host could be changed with WAC_KATTIS_HOST, like to a local fake judge */
+var KattisHost = util.PlatformHost("kattis", "https://open.kattis.com")
+
+/*
+ * Contest is /contests/<id>, problem is /problems/<id>, either standalone or
+ * as /contests/<id>/problems/<id> within the contest. Besides open.kattis.com,
+ * contests are hosted on subdomains, like nwerc.kattis.com.
+ */
+type problemRef struct {
+	base    string
+	contest string
+	problem string
+}
+
+func (r *problemRef) contestLink() string {
+	return r.base + "/contests/" + r.contest
+}
+
+func (r *problemRef) problemLink() string {
+	if len(r.contest) > 0 {
+		return r.contestLink() + "/problems/" + r.problem
+	}
+	return r.base + "/problems/" + r.problem
+}
+
+func parseUrl(link string) (*problemRef, error) {
+	page, err := util.ParsePageLink(link)
+	if err != nil {
+		return nil, err
+	}
+	if page.Host != "kattis.com" && !strings.HasSuffix(page.Host, ".kattis.com") && page.Host != util.HostOf(KattisHost) {
+		return nil, fmt.Errorf("bad Kattis URL")
+	}
+	ref := &problemRef{base: page.Base()}
+	parts := page.Parts()
+	if len(parts) >= 2 && parts[0] == "contests" && len(parts[1]) > 0 {
+		ref.contest = parts[1]
+		parts = parts[2:]
+		if len(parts) == 0 || (len(parts) == 1 && parts[0] == "problems") {
+			return ref, nil
+		}
+	}
+	if len(parts) >= 2 && parts[0] == "problems" && len(parts[1]) > 0 {
+		ref.problem = parts[1]
+		return ref, nil
+	}
+	return nil, fmt.Errorf("bad Kattis URL")
+}
+
+/* Response body of GET request, failing on any status except OK */
+func download(link string) ([]byte, error) {
+	client := &http.Client{Transport: util.PlatformTransport}
+	resp, err := client.Get(link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %s", link, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", link, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %s", link, err)
+	}
+	return body, nil
+}
+
+func retrieveDocument(link string) (*goquery.Selection, error) {
+	body, err := download(link)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", link, err)
+	}
+	return doc.Selection, nil
+}
+
+/* Heading of the page, the title is used when there is none */
+func pageTitle(doc *goquery.Selection) string {
+	if heading := strings.TrimSpace(doc.Find("h1").First().Text()); len(heading) > 0 {
+		return heading
+	}
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+	/* title is like "Name – Kattis, Kattis" */
+	if dash := strings.Index(title, " – "); dash >= 0 {
+		title = title[:dash]
+	}
+	return title
+}
+
+func (a Kattis) GetContest(link string, rootDirName string) (*model.Contest, error) {
+	ref, err := parseUrl(link)
+	if err != nil {
+		return nil, err
+	}
+	if len(ref.problem) > 0 {
+		return getSingleProblem(ref, rootDirName)
+	}
+	listLink := ref.contestLink() + "/problems"
+	doc, err := retrieveDocument(listLink)
+	if err != nil {
+		return nil, err
+	}
+	tasks := parseProblemList(doc, ref)
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no problems are found at %s, the contest may be not started yet", listLink)
+	}
+	return &model.Contest{Link: ref.contestLink(), Name: pageTitle(doc), Tasks: tasks, RootDir: rootDirName}, nil
+}
+
+/* Rows of the problem table hold a letter, like "A", and a link to the problem */
+func parseProblemList(doc *goquery.Selection, ref *problemRef) map[string]model.Task {
+	tasks := make(map[string]model.Task)
+	prefix := "/contests/" + ref.contest + "/problems/"
+	doc.Find("table tbody tr").Each(func(i int, s *goquery.Selection) {
+		var nameElement *goquery.Selection
+		s.Find("a[href]").EachWithBreak(func(j int, a *goquery.Selection) bool {
+			href, _ := a.Attr("href")
+			if strings.Contains(href, prefix) && len(strings.TrimSpace(a.Text())) > 0 {
+				nameElement = a
+				return false
+			}
+			return true
+		})
+		if nameElement == nil {
+			return
+		}
+		href, _ := nameElement.Attr("href")
+		problem := path.Base(href)
+		token := strings.ToLower(strings.TrimSpace(s.Find("th, td").First().Text()))
+		if len(token) == 0 || len(token) > 3 {
+			log.Printf("WARN no letter of problem %s, its ID is used as token\n", problem)
+			token = problem
+		}
+		task := model.Task{
+			Link:       (&problemRef{base: ref.base, contest: ref.contest, problem: problem}).problemLink(),
+			Name:       strings.TrimSpace(nameElement.Text()),
+			Token:      token,
+			TestTokens: make([]string, 0),
+		}
+		tasks[token] = task
+	})
+	return tasks
+}
+
+/* Contest with the only task referenced by problem URL, the problem ID is the token */
+func getSingleProblem(ref *problemRef, rootDirName string) (*model.Contest, error) {
+	link := ref.problemLink()
+	doc, err := retrieveDocument(link)
+	if err != nil {
+		return nil, err
+	}
+	name := pageTitle(doc)
+	task := model.Task{Link: link, Name: name, Token: ref.problem, TestTokens: make([]string, 0)}
+	parseLimits(doc, &task)
+	tasks := map[string]model.Task{ref.problem: task}
+	return &model.Contest{Link: link, Name: name, Tasks: tasks, RootDir: rootDirName}, nil
+}
+
+/* Start time isn't published in a stable format, so waiting for the start isn't supported */
+func (a Kattis) GetStartTime(link string) (time.Time, error) {
+	if _, err := parseUrl(link); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("start time of Kattis contests is unknown")
+}
+
+var timeLimitRegexp = regexp.MustCompile(`(?i)CPU Time limit\s*:?\s*([0-9.]+)\s*second`)
+var memoryLimitRegexp = regexp.MustCompile(`(?i)Memory limit\s*:?\s*([0-9]+)\s*MB`)
+
+/* Limits are given in the sidebar, like "CPU Time limit 1 second" and "Memory limit 1024 MB" */
+func parseLimits(doc *goquery.Selection, task *model.Task) {
+	text := doc.Text()
+	if match := timeLimitRegexp.FindStringSubmatch(text); match != nil {
+		if seconds, err := strconv.ParseFloat(match[1], 64); err == nil {
+			task.TimeLimit = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if match := memoryLimitRegexp.FindStringSubmatch(text); match != nil {
+		if megabytes, err := strconv.ParseUint(match[1], 10, 64); err == nil {
+			task.MemoryLimit = megabytes
+		}
+	}
+}
+
+/* Statements of interactive problems say so explicitly */
+func isInteractive(doc *goquery.Selection) bool {
+	return strings.Contains(strings.ToLower(doc.Text()), "this is an interactive problem")
+}
+
+func (a Kattis) GetStatement(task *model.Task) (*model.Statement, error) {
+	doc, err := retrieveDocument(task.Link)
+	if err != nil {
+		return nil, err
+	}
+	statementElement := doc.Find("div.problembody").First()
+	if statementElement.Length() == 0 {
+		return nil, fmt.Errorf("statement is not found at %s", task.Link)
+	}
+	content, err := statementElement.Html()
+	if err != nil {
+		return nil, err
+	}
+	return &model.Statement{Html: content, Link: task.Link}, nil
+}
+
+/* Samples of the archive are pairs of files, like "1.in" and "1.ans" */
+func parseSamples(archive []byte) ([]model.Test, error) {
+	archived, err := util.ReadTestArchive(archive)
+	if err != nil {
+		return nil, fmt.Errorf("bad archive of samples: %s", err)
+	}
+	var result []model.Test
+	for i, test := range archived {
+		token := fmt.Sprintf("sample%d", i+1)
+		result = append(result, model.Test{Token: token, Input: test.Input, Output: test.Output})
+	}
+	return result, nil
+}
+
+func (a Kattis) GetTests(task *model.Task) ([]model.Test, error) {
+	doc, err := retrieveDocument(task.Link)
+	if err != nil {
+		return nil, err
+	}
+	task.Interactive = isInteractive(doc)
+	parseLimits(doc, task)
+	archive, err := download(task.Link + "/file/statement/samples.zip")
+	if err != nil {
+		if task.Interactive {
+			log.Printf("WARN no sample tests for interactive task %s\n", task.Token)
+			return nil, nil
+		}
+		return nil, err
+	}
+	tests, err := parseSamples(archive)
+	if err != nil {
+		return nil, err
+	}
+	if len(tests) == 0 && !task.Interactive {
+		return nil, fmt.Errorf("no valid sample tests were found")
+	}
+	return tests, nil
+}
@@ -0,0 +1,59 @@
+package kattis
+
+import (
+	"testing"
+)
+
+func TestParseUrl(t *testing.T) {
+	cases := []struct {
+		link    string
+		ref     problemRef
+		problem string
+	}{
+		{"https://open.kattis.com/problems/hello", problemRef{base: "https://open.kattis.com", problem: "hello"},
+			"https://open.kattis.com/problems/hello"},
+		{"https://open.kattis.com/problems/hello/", problemRef{base: "https://open.kattis.com", problem: "hello"},
+			"https://open.kattis.com/problems/hello"},
+		{"https://nwerc22.kattis.com/problems/alternatingalgorithm?tab=metadata", problemRef{base: "https://nwerc22.kattis.com", problem: "alternatingalgorithm"},
+			"https://nwerc22.kattis.com/problems/alternatingalgorithm"},
+		{"https://open.kattis.com/contests/abc123", problemRef{base: "https://open.kattis.com", contest: "abc123"},
+			""},
+		{"https://open.kattis.com/contests/abc123/problems", problemRef{base: "https://open.kattis.com", contest: "abc123"},
+			""},
+		{"https://open.kattis.com/contests/abc123/problems/hello", problemRef{base: "https://open.kattis.com", contest: "abc123", problem: "hello"},
+			"https://open.kattis.com/contests/abc123/problems/hello"},
+		{"http://kattis.com/problems/hello#top", problemRef{base: "http://kattis.com", problem: "hello"},
+			"http://kattis.com/problems/hello"},
+	}
+	for _, c := range cases {
+		ref, err := parseUrl(c.link)
+		if err != nil {
+			t.Errorf("%s: %s", c.link, err)
+			continue
+		}
+		if *ref != c.ref {
+			t.Errorf("%s: expected %+v, got %+v", c.link, c.ref, *ref)
+		}
+		if len(ref.problem) > 0 && ref.problemLink() != c.problem {
+			t.Errorf("%s: expected problem link %s, got %s", c.link, c.problem, ref.problemLink())
+		}
+	}
+}
+
+func TestParseUrlErrors(t *testing.T) {
+	for _, link := range []string{
+		"open.kattis.com/problems/hello",
+		"https://open.kattis.com",
+		"https://open.kattis.com/problems",
+		"https://open.kattis.com/problems/",
+		"https://open.kattis.com/contests",
+		"https://open.kattis.com/contests/abc123/standings",
+		"https://open.kattis.com/users/tourist",
+		"https://kattis.com.example.com/problems/hello",
+		"https://codeforces.com/problems/hello",
+	} {
+		if ref, err := parseUrl(link); err == nil {
+			t.Errorf("%s: expected error, got %+v", link, ref)
+		}
+	}
+}
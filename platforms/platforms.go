@@ -4,6 +4,8 @@ import (
 	"github.com/mxwell/wac/model"
 	"github.com/mxwell/wac/platforms/atcoder"
 	"github.com/mxwell/wac/platforms/codeforces"
+	"github.com/mxwell/wac/platforms/cses"
+	"github.com/mxwell/wac/platforms/kattis"
 )
 
 var platformsList []model.Platform
@@ -12,6 +14,8 @@ func initPlatforms() []model.Platform {
 	if len(platformsList) == 0 {
 		platformsList = append(platformsList, atcoder.InitAtCoder())
 		platformsList = append(platformsList, codeforces.InitCodeforces())
+		platformsList = append(platformsList, kattis.InitKattis())
+		platformsList = append(platformsList, cses.InitCses())
 	}
 	return platformsList
 }
@@ -24,3 +28,20 @@ func FindPlatform(url string) model.Platform {
 	}
 	return nil
 }
+
+func FindPlatformByName(name string) model.Platform {
+	for _, platform := range initPlatforms() {
+		if platform.Name() == name {
+			return platform
+		}
+	}
+	return nil
+}
+
+func PlatformNames() []string {
+	var names []string
+	for _, platform := range initPlatforms() {
+		names = append(names, platform.Name())
+	}
+	return names
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+/* Test of an archive, its files share the name, like "1.in" and "1.ans" */
+type ArchivedTest struct {
+	Name   string
+	Input  string
+	Output string
+}
+
+/* Names sorted as numbers, when they are numbers, like "1", "2", ..., "10" */
+func lessTestName(a string, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+/* Tests of zip archive with inputs in .in files and outputs in .ans or .out ones, sorted by names */
+func ReadTestArchive(archive []byte) ([]ArchivedTest, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+	inputs := make(map[string]string)
+	outputs := make(map[string]string)
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		base := path.Base(file.Name)
+		ext := path.Ext(base)
+		var target map[string]string
+		switch ext {
+		case ".in":
+			target = inputs
+		case ".ans", ".out":
+			target = outputs
+		default:
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		target[strings.TrimSuffix(base, ext)] = string(content)
+	}
+	var names []string
+	for name := range inputs {
+		if _, ok := outputs[name]; ok {
+			names = append(names, name)
+		} else {
+			log.Printf("WARN no output for test %s in archive\n", name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return lessTestName(names[i], names[j]) })
+	result := make([]ArchivedTest, 0, len(names))
+	for _, name := range names {
+		result = append(result, ArchivedTest{Name: name, Input: inputs[name], Output: outputs[name]})
+	}
+	return result, nil
+}
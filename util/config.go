@@ -28,6 +28,8 @@ type Configuration struct {
 	DefaultBuildMethod string
 	RunMethods         map[string]ExecMethod
 	DefaultRunMethod   string
+	FetchStatements    bool   /* save statements during 'fetch --all' */
+	Pager              string /* used for statements when PAGER isn't set */
 }
 
 func GetDefaultLocation() string {
@@ -68,6 +70,7 @@ func initConfiguration() *Configuration {
 			"python3": ExecMethod{"python3 $OUTPUT.py"},
 		},
 		DefaultRunMethod: "elf",
+		Pager:            "less",
 	}
 	return conf
 }
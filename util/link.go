@@ -0,0 +1,52 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+const SchemeHttp = "http://"
+const SchemeHttps = "https://"
+
+/* Link to a page of a platform split into parts, the query is dropped */
+type PageLink struct {
+	Scheme   string
+	Host     string
+	Path     string
+	Fragment string
+}
+
+/* Scheme with the host, like "https://codeforces.com" */
+func (l *PageLink) Base() string {
+	return l.Scheme + l.Host
+}
+
+/* Segments of the path, like "contest", "1", "problem" and "A" */
+func (l *PageLink) Parts() []string {
+	return strings.Split(strings.Trim(l.Path, "/"), "/")
+}
+
+func ParsePageLink(link string) (*PageLink, error) {
+	result := &PageLink{}
+	if strings.HasPrefix(link, SchemeHttp) {
+		result.Scheme = SchemeHttp
+	} else if strings.HasPrefix(link, SchemeHttps) {
+		result.Scheme = SchemeHttps
+	} else {
+		return nil, fmt.Errorf("no valid scheme is detected in url - %s", link)
+	}
+	rest := link[len(result.Scheme):]
+	if hash := strings.Index(rest, "#"); hash >= 0 {
+		result.Fragment = rest[hash+1:]
+		rest = rest[:hash]
+	}
+	if query := strings.Index(rest, "?"); query >= 0 {
+		rest = rest[:query]
+	}
+	result.Host = rest
+	if slash := strings.Index(rest, "/"); slash >= 0 {
+		result.Host = rest[:slash]
+		result.Path = rest[slash:]
+	}
+	return result, nil
+}
@@ -0,0 +1,368 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/headzoo/surf/browser"
+	"gopkg.in/headzoo/surf.v1"
+)
+
+/* Cookie with the URL it was received from */
+type storedCookie struct {
+	Url    string
+	Cookie http.Cookie
+}
+
+/* Cookie jar which could be saved to a file and restored later */
+type PersistentJar struct {
+	path    string
+	jar     *cookiejar.Jar
+	mutex   sync.Mutex
+	cookies map[string]storedCookie
+	/* serializes writes to the file, so an older snapshot can't overwrite a newer one */
+	saveMutex sync.Mutex
+}
+
+func SessionPath(platform string) string {
+	return filepath.Join(GetDefaultLocation(), "sessions", platform+".json")
+}
+
+func RemoveSession(platform string) error {
+	err := os.Remove(SessionPath(platform))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func cookieKey(u *url.URL, cookie *http.Cookie) string {
+	domain := cookie.Domain
+	if len(domain) == 0 {
+		domain = u.Host
+	}
+	return domain + "|" + cookie.Path + "|" + cookie.Name
+}
+
+/* Create jar with cookies from the file, missing file means empty session */
+func LoadJar(path string) (*PersistentJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	result := &PersistentJar{path: path, jar: jar, cookies: make(map[string]storedCookie)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []storedCookie
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, s := range stored {
+		if !s.Cookie.Expires.IsZero() && s.Cookie.Expires.Before(now) {
+			continue
+		}
+		u, err := url.Parse(s.Url)
+		if err != nil {
+			continue
+		}
+		cookie := s.Cookie
+		result.SetCookies(u, []*http.Cookie{&cookie})
+	}
+	return result, nil
+}
+
+func (j *PersistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.jar.SetCookies(u, cookies)
+	origin := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+	for _, cookie := range cookies {
+		j.cookies[cookieKey(u, cookie)] = storedCookie{origin.String(), *cookie}
+	}
+}
+
+func (j *PersistentJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.jar.Cookies(u)
+}
+
+/* Forget all cookies, both in memory and in the file */
+func (j *PersistentJar) Clear() error {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	j.jar = jar
+	j.cookies = make(map[string]storedCookie)
+	j.mutex.Unlock()
+	return j.Save()
+}
+
+/* Write cookies to the file readable only by the user */
+func (j *PersistentJar) Save() error {
+	j.saveMutex.Lock()
+	defer j.saveMutex.Unlock()
+	j.mutex.Lock()
+	stored := make([]storedCookie, 0, len(j.cookies))
+	for _, s := range j.cookies {
+		stored = append(stored, s)
+	}
+	j.mutex.Unlock()
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+	/* write to a temporary file first, so the session is never left half-written */
+	tmp, err := ioutil.TempFile(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+/*
+ * Session of a platform: cookies are shared by browsers of all workers and
+ * saved between runs. When the session is expired, only one worker logs in,
+ * others wait for it and reuse the session.
+ */
+type Session struct {
+	name       string
+	mutex      sync.Mutex
+	jar        *PersistentJar
+	loginMutex sync.Mutex
+}
+
+/* Session saved in the file named after the platform */
+func NewSession(name string) *Session {
+	return &Session{name: name}
+}
+
+/* Cookies of the session saved by previous runs */
+func (s *Session) Jar() (*PersistentJar, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.jar == nil {
+		jar, err := LoadJar(SessionPath(s.name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session: %s", err)
+		}
+		s.jar = jar
+	}
+	return s.jar, nil
+}
+
+func (s *Session) NewBrowser() (*browser.Browser, error) {
+	jar, err := s.Jar()
+	if err != nil {
+		return nil, err
+	}
+	bow := surf.NewBrowser()
+	bow.SetCookieJar(jar)
+	bow.SetTransport(PlatformTransport)
+	return bow, nil
+}
+
+/* Cookies could be refreshed by the server with any response */
+func (s *Session) Save() error {
+	jar, err := s.Jar()
+	if err != nil {
+		return err
+	}
+	if err := jar.Save(); err != nil {
+		return fmt.Errorf("failed to save session: %s", err)
+	}
+	return nil
+}
+
+/*
+ * Open the page, logging in if it's denied without login, like when the
+ * login form is shown instead. Login is given the browser and the session is
+ * saved after it.
+ */
+func (s *Session) OpenLoggedIn(bow *browser.Browser, link string, denied func(bow *browser.Browser) bool, login func(bow *browser.Browser) error) error {
+	err := bow.Open(link)
+	if err != nil {
+		return fmt.Errorf("failed to fetch requested page - %s: %s", link, err)
+	}
+	if denied(bow) {
+		if err := s.loginAndReopen(bow, link, denied, login); err != nil {
+			return err
+		}
+	}
+	if err := s.Save(); err != nil {
+		log.Printf("WARN %s\n", err)
+	}
+	return nil
+}
+
+func (s *Session) loginAndReopen(bow *browser.Browser, link string, denied func(bow *browser.Browser) bool, login func(bow *browser.Browser) error) error {
+	s.loginMutex.Lock()
+	defer s.loginMutex.Unlock()
+	/* another worker could log in meanwhile */
+	err := bow.Open(link)
+	if err != nil {
+		return fmt.Errorf("failed to fetch requested page - %s: %s", link, err)
+	}
+	if !denied(bow) {
+		return nil
+	}
+	if err := login(bow); err != nil {
+		return err
+	}
+	if err := s.Save(); err != nil {
+		return err
+	}
+	err = bow.Open(link)
+	if err != nil {
+		return fmt.Errorf("failed to fetch requested page - %s: %s", link, err)
+	}
+	if denied(bow) {
+		return fmt.Errorf("access to %s is denied after login", link)
+	}
+	return nil
+}
+
+/*
+ * Body of GET request with cookies of the session, for responses which aren't
+ * pages, like JSON or archives. The final URL tells where redirects have led,
+ * like to the login page.
+ */
+func (s *Session) Download(link string) ([]byte, *url.URL, error) {
+	jar, err := s.Jar()
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &http.Client{Jar: jar, Transport: PlatformTransport}
+	resp, err := client.Get(link)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %s", link, err)
+	}
+	return readResponse(link, resp)
+}
+
+/* Body of POST request of the form with cookies of the session, like the one downloading an archive */
+func (s *Session) PostForm(link string, data url.Values) ([]byte, *url.URL, error) {
+	jar, err := s.Jar()
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &http.Client{Jar: jar, Transport: PlatformTransport}
+	resp, err := client.PostForm(link, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to post to %s: %s", link, err)
+	}
+	return readResponse(link, resp)
+}
+
+func readResponse(link string, resp *http.Response) ([]byte, *url.URL, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %s", link, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %s", link, err)
+	}
+	return body, resp.Request.URL, nil
+}
+
+/*
+ * Download the response which isn't a page, like JSON, logging in when it's
+ * denied without login. Denial is recognized by the final URL, like the
+ * login page the request was redirected to.
+ */
+func (s *Session) DownloadLoggedIn(link string, denied func(final *url.URL) bool, login func(bow *browser.Browser) error) ([]byte, error) {
+	body, final, err := s.Download(link)
+	if err != nil {
+		return nil, err
+	}
+	if denied(final) {
+		s.loginMutex.Lock()
+		/* another worker could log in meanwhile */
+		body, final, err = s.Download(link)
+		if err == nil && denied(final) {
+			if err = s.loginWithBrowser(login); err == nil {
+				body, final, err = s.Download(link)
+			}
+		}
+		s.loginMutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if denied(final) {
+			return nil, fmt.Errorf("access to %s is denied after login", link)
+		}
+	}
+	if err := s.Save(); err != nil {
+		log.Printf("WARN %s\n", err)
+	}
+	return body, nil
+}
+
+func (s *Session) loginWithBrowser(login func(bow *browser.Browser) error) error {
+	bow, err := s.NewBrowser()
+	if err != nil {
+		return err
+	}
+	if err := login(bow); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+/* Log in with fresh session and save it for the next runs */
+func (s *Session) Login(login func(bow *browser.Browser) error) error {
+	jar, err := s.Jar()
+	if err != nil {
+		return err
+	}
+	if err := jar.Clear(); err != nil {
+		return fmt.Errorf("failed to reset session: %s", err)
+	}
+	return s.loginWithBrowser(login)
+}
+
+/* Forget the session, both in memory and in the file */
+func (s *Session) Remove() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jar = nil
+	return RemoveSession(s.name)
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Settings of requests to platforms, zero timeout means no timeout */
+var RequestTimeout = 20 * time.Second
+var RequestRetries = 3
+var RetryDelay = time.Second
+var HostInterval = 500 * time.Millisecond
+
+/* Spreads requests to one host in time, so the platform doesn't ban us */
+type hostLimiter struct {
+	mutex sync.Mutex
+	next  map[string]time.Time
+}
+
+var limiter = &hostLimiter{next: make(map[string]time.Time)}
+
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	l.mutex.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(HostInterval)
+	l.mutex.Unlock()
+	delay := at.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/* Releases the timeout of the request once its body is read */
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+/*
+ * Transport for requests to platforms: requests are rate limited per host and
+ * have a timeout, idempotent ones are retried with exponential backoff when
+ * they fail or the server is overloaded.
+ */
+type Transport struct {
+	base http.RoundTripper
+}
+
+var PlatformTransport http.RoundTripper = &Transport{base: http.DefaultTransport}
+
+func (t *Transport) attempt(req *http.Request) (*http.Response, error) {
+	if err := limiter.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	if RequestTimeout == 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), RequestTimeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if (req.Method == "GET" || req.Method == "HEAD") && req.Body == nil {
+		retries = RequestRetries
+	}
+	delay := RetryDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req)
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			reason = resp.Status
+		} else {
+			return resp, nil
+		}
+		if attempt >= retries {
+			if err != nil {
+				return nil, fmt.Errorf("%s (after %d attempt(s))", err, attempt+1)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		log.Printf("WARN request to %s failed: %s, retrying in %s\n", req.URL, reason, delay)
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		delay *= 2
+	}
+}
+
+/* Base URL of the platform, it could be overridden by WAC_<PLATFORM>_HOST, like for a local fake judge */
+func PlatformHost(platform string, defaultHost string) string {
+	if host := os.Getenv("WAC_" + strings.ToUpper(platform) + "_HOST"); len(host) > 0 {
+		return strings.TrimSuffix(host, "/")
+	}
+	return defaultHost
+}
+
+/* Host part of the base URL, like "codeforces.com" */
+func HostOf(base string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -1,7 +1,10 @@
 package util
 
 import (
+	"io"
 	"os"
+	"regexp"
+	"strings"
 )
 
 func ContainsString(arr *[]string, value string) bool {
@@ -20,3 +23,33 @@ func PathExists(path string) bool {
 		return true
 	}
 }
+
+func CopyFile(source string, destination string) error {
+	input, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	_, err = io.Copy(output, input)
+	cerr := output.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return cerr
+}
+
+var nonSlugRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+/* Lowercase name with runs of other characters replaced by "-", like "weird-algorithm" */
+func Slug(s string) string {
+	return strings.Trim(nonSlugRegexp.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}